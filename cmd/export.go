@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/northpolesec/santa-rule-importer/mobileconfig"
+	"github.com/northpolesec/santa-rule-importer/morozconfig"
+	"github.com/northpolesec/santa-rule-importer/rudolph"
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/northpolesec/santa-rule-importer/santactl"
+	"github.com/northpolesec/santa-rule-importer/source"
+	"github.com/northpolesec/santa-rule-importer/workshop"
+	"google.golang.org/protobuf/proto"
+
+	svcpb "buf.build/gen/go/northpolesec/workshop-api/grpc/go/workshop/v1/workshopv1grpc"
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// exportWriters maps each export format to the function that writes it.
+// Profiles are written with the given options.
+func exportWriters(profile mobileconfig.ProfileOptions) map[string]func(io.Writer, []*apipb.Rule) error {
+	return map[string]func(io.Writer, []*apipb.Rule) error{
+		"toml": morozconfig.WriteRules,
+		"csv":  rudolph.WriteRules,
+		"json": func(w io.Writer, rules []*apipb.Rule) error {
+			var rulesFile santactl.RulesFile
+			for _, rule := range rules {
+				rulesFile.Rules = append(rulesFile.Rules, santactl.FromWorkshopRule(rule))
+			}
+			return santactl.WriteRules(w, rulesFile)
+		},
+		"mobileconfig": func(w io.Writer, rules []*apipb.Rule) error {
+			return mobileconfig.WriteProfile(w, rules, profile)
+		},
+	}
+}
+
+func exportUsage(fs *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s export [OPTIONS] <server> <output file|->\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s export [OPTIONS] --source <name> <source file or location> <output file|->\n", os.Args[0])
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "Export every rule in a Workshop instance, or read from another source, as a\n")
+		fmt.Fprintf(os.Stderr, "Moroz TOML config, a Rudolph CSV export, a santactl JSON export or a Santa\n")
+		fmt.Fprintf(os.Stderr, "configuration profile with StaticRules\n")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "This tool expects the Workshop API Key to be in the WORKSHOP_API_KEY env var\n")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "  Example Usage:")
+		fmt.Fprintf(os.Stderr, "\t%s export nps.workshop.cloud global.toml\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\t%s export --format json nps.workshop.cloud - > rules.json\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\t%s export --source rudolph --organization Example rules.csv santa.mobileconfig\n", os.Args[0])
+		os.Exit(1)
+	}
+}
+
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	useInsecure := fs.Bool("insecure", false, "Use insecure connection")
+	format := fs.String("format", "", "Output format (toml, csv, json or mobileconfig), defaults to the output file extension")
+	sourceName := fs.String("source", "", "Read rules from this source instead of Workshop")
+	ruleTypes := fs.String("rule-types", "", "Only export rules of these comma separated types (e.g., BINARY,TEAMID)")
+	policies := fs.String("policies", "", "Only export rules with these comma separated policies (e.g., BLOCKLIST)")
+	includeTagged := fs.Bool("include-tagged", false, "Export rules scoped to a tag as global rules instead of skipping them")
+	identifierValidation := fs.String("identifier-validation", "reject", "How to handle malformed identifiers (reject, warn or off)")
+	skipInvalid := fs.Bool("skip-invalid", false, "Skip and report invalid source records instead of aborting the export")
+	var profile mobileconfig.ProfileOptions
+	fs.StringVar(&profile.Identifier, "payload-identifier", mobileconfig.DefaultIdentifier, "PayloadIdentifier of a mobileconfig profile")
+	fs.StringVar(&profile.DisplayName, "payload-display-name", mobileconfig.DefaultDisplayName, "PayloadDisplayName of a mobileconfig profile")
+	fs.StringVar(&profile.Organization, "organization", "", "PayloadOrganization of a mobileconfig profile")
+	source.RegisterFlags(fs)
+	fs.Usage = exportUsage(fs)
+	fs.Parse(args)
+
+	filter, err := rulehelpers.ParseFilter(*ruleTypes, *policies)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --rule-types or --policies: %v\n", err)
+		os.Exit(1)
+	}
+	validation, err := source.ParseIdentifierValidation(*identifierValidation)
+	if err != nil {
+		println("--identifier-validation must be one of reject, warn or off.")
+		os.Exit(1)
+	}
+
+	// Rules are read from Workshop unless another source is selected.
+	var (
+		sel            *source.Selection
+		server, output string
+	)
+	if _, ok := source.FlagSelected(fs); ok || *sourceName != "" {
+		s, err := source.Select(fs, *sourceName, fs.Args())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n\n", err)
+			fs.Usage()
+		}
+		if len(s.Args) < 1 {
+			fs.Usage()
+		}
+		sel, output = &s, s.Args[0]
+	} else {
+		if fs.NArg() < 2 {
+			fs.Usage()
+		}
+		server, output = fs.Arg(0), fs.Arg(1)
+	}
+
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(output)), ".")
+	}
+	write, ok := exportWriters(profile)[*format]
+	if !ok {
+		println("Unsupported export format. Please pass --format toml, csv, json or mobileconfig.")
+		os.Exit(1)
+	}
+
+	var rules []*apipb.Rule
+	if sel != nil {
+		var recordErrs rulehelpers.RecordErrors
+		_, rules, recordErrs = readSource(sel, validation)
+		if len(recordErrs) > 0 && !*skipInvalid {
+			log.Fatalf("Found %d invalid records, fix them or pass --skip-invalid to export the remaining rules", len(recordErrs))
+		}
+	} else {
+		rules = exportFromWorkshop(server, *useInsecure)
+	}
+	rules = filter.Apply(rules)
+
+	// None of the export formats can scope a rule to a set of hosts, so
+	// exporting a tagged rule would apply it to every host.
+	var global []*apipb.Rule
+	tagged := 0
+	for _, rule := range rules {
+		if tag := rule.GetTag(); tag != "" && tag != "global" {
+			tagged++
+			if !*includeTagged {
+				log.Printf("Skipping %s rule %s scoped to tag %q\n", rule.GetRuleType(), rule.GetIdentifier(), tag)
+				continue
+			}
+			rule = proto.Clone(rule).(*apipb.Rule)
+			rule.Tag = ""
+		}
+		global = append(global, rule)
+	}
+	if tagged > 0 {
+		if *includeTagged {
+			log.Printf("Warning: %d rules are scoped to a tag, they are exported as global rules\n", tagged)
+		} else {
+			log.Printf("Skipped %d rules scoped to a tag, pass --include-tagged to export them as global rules\n", tagged)
+		}
+	}
+	rules = global
+
+	if output == "-" {
+		err = write(os.Stdout, rules)
+	} else {
+		var f *os.File
+		f, err = os.Create(output)
+		if err != nil {
+			log.Fatalf("Failed to create output file: %v", err)
+		}
+		err = errors.Join(write(f, rules), f.Close())
+	}
+	if err != nil {
+		log.Fatalf("Failed to write rules: %v", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %d rules\n", len(rules))
+}
+
+// exportFromWorkshop returns every rule in the Workshop instance at server.
+func exportFromWorkshop(server string, useInsecure bool) []*apipb.Rule {
+	apiKey := os.Getenv("WORKSHOP_API_KEY")
+	if apiKey == "" {
+		println("Please set WORKSHOP_API_KEY environment variable with your API key.")
+		os.Exit(1)
+	}
+
+	conn, err := workshop.Dial(server, apiKey, useInsecure)
+	if err != nil {
+		log.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+
+	rules, err := workshop.ListRules(context.Background(), svcpb.NewWorkshopServiceClient(conn))
+	if err != nil {
+		log.Fatalf("Failed to list rules: %v", err)
+	}
+	return rules
+}
+
+// readSource returns the selected source and the rules read from it, along
+// with the invalid records that were skipped. Invalid records and malformed
+// identifiers are logged.
+func readSource(sel *source.Selection, validation source.IdentifierValidation) (source.Source, []*apipb.Rule, rulehelpers.RecordErrors) {
+	src, err := sel.Open()
+	if err != nil {
+		log.Fatalf("Failed to open source: %v", err)
+	}
+
+	// Records that could not be converted are reported alongside the valid
+	// rules; anything else means the source could not be read at all.
+	rules, recordErrs, warnings, err := source.Read(context.Background(), src, validation)
+	if err != nil {
+		log.Fatalf("Failed to read rules from %s source: %v", sel.Name, err)
+	}
+	for _, warning := range warnings {
+		log.Printf("Warning: malformed identifier %v\n", warning)
+	}
+	for _, recordErr := range recordErrs {
+		log.Printf("Invalid record %v\n", recordErr)
+	}
+
+	return src, rules, recordErrs
+}
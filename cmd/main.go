@@ -4,160 +4,295 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"strings"
+	"time"
 
-	"github.com/northpolesec/santa-rule-importer/internal/morozconfig"
-	"github.com/northpolesec/santa-rule-importer/internal/rudolph"
-	"github.com/northpolesec/santa-rule-importer/internal/santactl"
-	"github.com/northpolesec/santa-rule-importer/internal/zentral"
+	"github.com/northpolesec/santa-rule-importer/importer"
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/northpolesec/santa-rule-importer/santactl"
+	"github.com/northpolesec/santa-rule-importer/source"
+	"github.com/northpolesec/santa-rule-importer/workshop"
 
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 
 	svcpb "buf.build/gen/go/northpolesec/workshop-api/grpc/go/workshop/v1/workshopv1grpc"
 	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
 )
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS] <path to config.toml|path to config.csv> <server>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS] <source file, - for stdin, or location> <server>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s export [OPTIONS] <server> <output file|->\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s settings [OPTIONS] <moroz config file or configs directory> [server]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s push [OPTIONS] <server> <zentral url>\n", os.Args[0])
 	fmt.Fprintln(os.Stderr)
 	fmt.Fprintf(os.Stderr, "santa-rule-importer - tool to import rules from Moroz, Rudolph, and Zentral to Workshop\n")
 	fmt.Fprintln(os.Stderr)
 	fmt.Fprintf(os.Stderr, "This tool expects the Workshop API Key to be in the WORKSHOP_API_KEY env var\n")
 	fmt.Fprintf(os.Stderr, "For Zentral imports, set ZENTRAL_API_KEY env var with your Zentral API token\n")
+	fmt.Fprintf(os.Stderr, "For Workshop to Workshop imports, set WORKSHOP_SOURCE_API_KEY env var with the source API key\n")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Sources:")
+	for _, reg := range source.Registrations() {
+		selectors := append([]string{}, reg.Extensions...)
+		if reg.LocationFlag != "" {
+			selectors = append(selectors, "--"+reg.LocationFlag)
+		}
+		description := reg.Description
+		if len(selectors) > 0 {
+			description += " (" + strings.Join(selectors, ", ") + ")"
+		}
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", reg.Name, description)
+	}
 	fmt.Fprintln(os.Stderr)
 	flag.PrintDefaults()
 	fmt.Fprintln(os.Stderr)
 	fmt.Fprintln(os.Stderr, "  Example Usage:")
 	fmt.Fprintf(os.Stderr, "\t%s global.toml nps.workshop.cloud\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "\tcurl -s https://example.com/rules.csv | %s - nps.workshop.cloud\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "\t%s --zentral-url zentral.example.com nps.workshop.cloud\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "\t%s --workshop-source staging.workshop.cloud nps.workshop.cloud\n", os.Args[0])
 	os.Exit(1)
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		runExport(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "settings" {
+		runSettings(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "push" {
+		runPush(os.Args[2:])
+		return
+	}
+
 	useInsecure := flag.Bool("insecure", false, "Use insecure connection")
-	useCustomMsgAsComment := flag.Bool("use-custom-msg-as-comment", false, "Use custom message as comment (moroz only)")
-	zentBaseURL := flag.String("zentral-url", "", "Zentral base URL (e.g., zentral.example.com)")
-	zentTargetType := flag.String("zentral-target-type", "", "Filter Zentral rules by target type (BINARY, CERTIFICATE, etc.)")
-	zentTargetIdentifier := flag.String("zentral-target-identifier", "", "Filter Zentral rules by target identifier")
-	zentConfigID := flag.Int("zentral-config-id", 0, "Filter Zentral rules by configuration ID")
+	syncMode := flag.Bool("sync", false, "Only create missing rules and update changed rules already in Workshop")
+	dryRun := flag.Bool("dry-run", false, "Print the changes --sync would make without modifying Workshop")
+	planFormat := flag.String("plan-format", "text", "Output format for --dry-run (text or json)")
+	concurrency := flag.Int("concurrency", 1, "Number of rules to send to Workshop in parallel")
+	rateLimit := flag.Float64("rate-limit", 0, "Maximum requests per second sent to Workshop (0 for no limit)")
+	maxAttempts := flag.Int("max-attempts", 5, "Number of times to send a request that fails with a transient error")
+	rpcTimeout := flag.Duration("rpc-timeout", 30*time.Second, "Timeout for each request sent to Workshop (0 for no timeout)")
+	journalPath := flag.String("journal", "", "Record the outcome of each rule in this file so an interrupted import can be resumed")
+	resume := flag.Bool("resume", false, "Skip rules the --journal file records as already applied")
+	resumeChanged := flag.Bool("resume-changed-source", false, "With --resume, continue even if the source has changed since the journal was written")
+	failedRulesOut := flag.String("failed-rules-out", "", "Write rules that failed to import to this file in santactl JSON format")
+	identifierValidation := flag.String("identifier-validation", "reject", "How to handle malformed identifiers (reject, warn or off)")
+	skipInvalid := flag.Bool("skip-invalid", false, "Skip and report invalid source records instead of aborting the import")
+	sourceName := flag.String("source", "", "Read rules from this source instead of choosing one by file extension")
+	format := flag.String("format", "", "Format of the source file (toml, csv or json), detected from its contents if not given")
+	ruleTypes := flag.String("rule-types", "", "Only import rules of these comma separated types (e.g., BINARY,TEAMID)")
+	policies := flag.String("policies", "", "Only import rules with these comma separated policies (e.g., BLOCKLIST)")
+
+	source.RegisterFlags(flag.CommandLine)
 
 	flag.Usage = usage
 	flag.Parse()
 
 	args := flag.Args()
 
+	if *planFormat != "text" && *planFormat != "json" {
+		println("--plan-format must be either text or json.")
+		os.Exit(1)
+	}
+
+	filter, err := rulehelpers.ParseFilter(*ruleTypes, *policies)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --rule-types or --policies: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *resume && *journalPath == "" {
+		println("--resume requires --journal.")
+		os.Exit(1)
+	}
+	if *resumeChanged && !*resume {
+		println("--resume-changed-source requires --resume.")
+		os.Exit(1)
+	}
+
+	validation, err := source.ParseIdentifierValidation(*identifierValidation)
+	if err != nil {
+		println("--identifier-validation must be one of reject, warn or off.")
+		os.Exit(1)
+	}
+
 	apiKey := os.Getenv("WORKSHOP_API_KEY")
 	if apiKey == "" {
 		println("Please set WORKSHOP_API_KEY environment variable with your API key.")
 		os.Exit(1)
 	}
 
-	var (
-		rules      []*apipb.Rule
-		ruleSrcErr error
-		server     string
-	)
-
-	// Check if using Zentral API or file input
-	if *zentBaseURL != "" {
-		// Handle Zentral API import
-		if len(args) < 1 {
-			println("Server address required for Zentral imports.")
-			usage()
+	if *format != "" {
+		reg, ok := source.ForFormat(*format)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Unknown --format %q.\n", *format)
+			os.Exit(1)
 		}
-		server = args[0]
-
-		zentAPIKey := os.Getenv("ZENTRAL_API_KEY")
-		if zentAPIKey == "" {
-			println("Please set ZENTRAL_API_KEY environment variable for Zentral imports.")
+		if *sourceName != "" && *sourceName != reg.Name {
+			println("--format and --source select different sources.")
 			os.Exit(1)
 		}
+		*sourceName = reg.Name
+	}
 
-		baseURL := *zentBaseURL
-		if !strings.HasPrefix(baseURL, "http") {
-			baseURL = "https://" + baseURL
-		}
+	sel, err := source.Select(flag.CommandLine, *sourceName, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n\n", err)
+		usage()
+	}
+	if len(sel.Args) < 1 {
+		println("Server address required.")
+		usage()
+	}
+	server := sel.Args[0]
 
-		rules, ruleSrcErr = zentral.GetRulesFromZentral(baseURL, zentAPIKey, *zentTargetType, *zentTargetIdentifier, *zentConfigID)
-	} else {
-		// Handle file input
-		if len(args) < 2 {
-			usage()
-		}
-		filename := args[0]
-		server = args[1]
-
-		// Check the file extension and parse CSVs from rudolph or TOML files from moroz.
-		if strings.HasSuffix(filename, ".csv") {
-			rules, ruleSrcErr = rudolph.ParseRulesFromFile(filename)
-		} else if strings.HasSuffix(filename, ".toml") {
-			rules, ruleSrcErr = morozconfig.ParseRulesFromFile(filename, *useCustomMsgAsComment)
-		} else if strings.HasSuffix(filename, ".json") {
-			rules, ruleSrcErr = santactl.ParseRulesFromFile(filename)
-		} else {
-			println("Unsupported file format. Please provide a .toml, .csv, or .json file.")
-			os.Exit(1)
-		}
+	src, err := sel.Open()
+	if err != nil {
+		log.Fatalf("Failed to open source: %v", err)
 	}
 
-	if ruleSrcErr != nil {
-		if *zentBaseURL != "" {
-			log.Fatalf("Failed to retrieve rules from Zentral: %v", ruleSrcErr)
+	conn, err := workshop.Dial(server, apiKey, *useInsecure)
+	if err != nil {
+		log.Fatalf("Failed to connect to server: %v", err)
+	}
+
+	imp := &importer.Importer{
+		Client:               svcpb.NewWorkshopServiceClient(conn),
+		Sync:                 *syncMode,
+		DryRun:               *dryRun,
+		Filter:               filter,
+		IdentifierValidation: validation,
+		SkipInvalid:          *skipInvalid,
+		Journal:              *journalPath,
+		Resume:               *resume,
+		ResumeChangedSource:  *resumeChanged,
+		Logf: func(format string, args ...any) {
+			log.Printf(format+"\n", args...)
+		},
+		Options: importer.Options{
+			Concurrency: *concurrency,
+			RateLimit:   *rateLimit,
+			MaxAttempts: *maxAttempts,
+			Timeout:     *rpcTimeout,
+		},
+	}
+
+	plan, result, err := imp.ImportSource(context.Background(), src)
+	var invalidErr *importer.InvalidRecordsError
+	switch {
+	case plan != nil:
+	case errors.As(err, &invalidErr):
+		log.Fatalf("Found %d invalid records, fix them or pass --skip-invalid to import the remaining rules", len(invalidErr.Records))
+	case errors.Is(err, importer.ErrSourceChanged):
+		log.Fatalf("The source has changed since the journal was written, pass --resume-changed-source to resume anyway")
+	default:
+		log.Fatalf("Failed to import rules from %s source: %v", sel.Name, err)
+	}
+
+	if *dryRun {
+		if *planFormat == "json" {
+			err = plan.WriteJSON(os.Stdout)
 		} else {
-			log.Fatalf("Failed to read config file: %v", ruleSrcErr)
+			err = plan.WriteText(os.Stdout)
+		}
+		if err != nil {
+			log.Fatalf("Failed to write plan: %v", err)
 		}
+		return
 	}
 
-	opts := []grpc.DialOption{
-		grpc.WithPerRPCCredentials(apiKeyAuthorizer(apiKey)),
+	if *resume {
+		fmt.Printf("Skipped %d rules already applied according to the journal\n", result.Skipped)
 	}
+	logFailures(result)
+	writeFailedRules(*failedRulesOut, result)
 
-	if *useInsecure {
-		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if *syncMode {
+		fmt.Printf("%d created, %d updated, %d unchanged, %d invalid, %d failed\n",
+			result.Created, result.Updated, result.Unchanged, result.Invalid, result.Failed)
 	} else {
-		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{})))
+		fmt.Printf("%d/%d rules added successfully!\n", result.Created, len(plan.Create))
+		if result.Invalid > 0 {
+			fmt.Printf("%d invalid records skipped\n", result.Invalid)
+		}
 	}
+	printFailureSummary(result)
 
-	conn, err := grpc.NewClient(fmt.Sprintf("dns:%s", server), opts...)
 	if err != nil {
-		log.Fatalf("Failed to connect to server: %v", err)
+		log.Fatalf("Failed to import rules from %s source: %v", sel.Name, err)
 	}
+}
 
-	// Create a gRPC client
-	client := svcpb.NewWorkshopServiceClient(conn)
-	req := &apipb.CreateRuleRequest{}
+// logFailures logs every rule that could not be applied, in plan order.
+func logFailures(result importer.Result) {
+	for _, r := range result.Rules {
+		switch {
+		case r.Action == importer.ActionDelete:
+			log.Printf("Deleted %s from Workshop but could not recreate it: %v\n", describeRule(r.Rule), r.Err)
+		case r.Exhausted():
+			log.Printf("Failed to %s %s after %d attempts: %v\n", r.Action, describeRule(r.Rule), r.Attempts, r.Err)
+		case r.Err != nil:
+			log.Printf("Failed to %s %s: %v\n", r.Action, describeRule(r.Rule), r.Err)
+		}
+	}
+}
 
-	successes := len(rules)
+// describeRule identifies a rule by its type, identifier and tag.
+func describeRule(rule *apipb.Rule) string {
+	if tag := rule.GetTag(); tag != "" {
+		return fmt.Sprintf("%s rule %s tagged %q", rule.GetRuleType(), rule.GetIdentifier(), tag)
+	}
+	return fmt.Sprintf("%s rule %s", rule.GetRuleType(), rule.GetIdentifier())
+}
 
-	// Iterate over the rules and add them to the Workshop instance
-	for i, rule := range rules {
-		req.Rule = rule
-		_, err := client.CreateRule(context.Background(), req)
+// writeFailedRules writes every rule that could not be applied, along with its
+// gRPC status, to path so that it can be fixed and imported again. Nothing is
+// written if path is empty or no rules failed.
+func writeFailedRules(path string, result importer.Result) {
+	if path == "" || result.Failed == 0 {
+		return
+	}
 
-		if err != nil {
-			log.Printf("Failed to add rule %d: %s %v\n", i, rule.GetIdentifier(), err)
-			successes--
+	var failed santactl.RulesFile
+	for _, r := range result.Rules {
+		if r.Err == nil {
 			continue
 		}
+		rule := santactl.FromWorkshopRule(r.Rule)
+		st := status.Convert(r.Err)
+		rule.ErrorCode = st.Code().String()
+		rule.ErrorMessage = st.Message()
+		failed.Rules = append(failed.Rules, rule)
 	}
 
-	fmt.Printf("%d/%d rules added successfully!\n", successes, len(rules))
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("Failed to create failed rules file: %v", err)
+	}
+	if err := errors.Join(santactl.WriteRules(f, failed), f.Close()); err != nil {
+		log.Fatalf("Failed to write failed rules file: %v", err)
+	}
+	fmt.Printf("Wrote %d failed rules to %s\n", len(failed.Rules), path)
 }
 
-// apiKeyAuthorizer is a custom authorizer that adds the API key to the request
-// metadata.
-type apiKeyAuthorizer string
-
-func (k apiKeyAuthorizer) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
-	return map[string]string{"Authorization": string(k)}, nil
-}
-func (k apiKeyAuthorizer) RequireTransportSecurity() bool {
-	return false
+// printFailureSummary separates rules Workshop rejected from those that kept
+// failing with transient errors and may succeed if the import is re-run.
+func printFailureSummary(result importer.Result) {
+	if result.Failed == 0 {
+		return
+	}
+	fmt.Printf("%d rules rejected by Workshop, %d rules failed after exhausting retries\n",
+		result.Rejected, result.Exhausted)
+	if result.Deleted > 0 {
+		fmt.Printf("%d rules were deleted from Workshop by failed updates, re-run the import to recreate them\n", result.Deleted)
+	}
 }
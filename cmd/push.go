@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/northpolesec/santa-rule-importer/source"
+	"github.com/northpolesec/santa-rule-importer/zentral"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+func pushUsage(fs *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s push [OPTIONS] <server> <zentral url>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s push [OPTIONS] --source <name> <source file or location> <zentral url>\n", os.Args[0])
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "Push every rule in a Workshop instance, or read from another source, to a\n")
+		fmt.Fprintf(os.Stderr, "Zentral Santa configuration. Missing rules are created and changed rules\n")
+		fmt.Fprintf(os.Stderr, "updated; with --delete, rules missing from the rule set are deleted\n")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "This tool expects the Zentral API token to be in the ZENTRAL_API_KEY env var,\n")
+		fmt.Fprintf(os.Stderr, "and the Workshop API Key to be in the WORKSHOP_API_KEY env var\n")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "  Example Usage:")
+		fmt.Fprintf(os.Stderr, "\t%s push --configuration Default --dry-run nps.workshop.cloud zentral.example.com\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\t%s push --configuration-id 3 --delete --source moroz global.toml zentral.example.com\n", os.Args[0])
+		os.Exit(1)
+	}
+}
+
+func runPush(args []string) {
+	fs := flag.NewFlagSet("push", flag.ExitOnError)
+	useInsecure := fs.Bool("insecure", false, "Use insecure connection")
+	sourceName := fs.String("source", "", "Read rules from this source instead of Workshop")
+	ruleTypes := fs.String("rule-types", "", "Only push rules of these comma separated types (e.g., BINARY,TEAMID)")
+	policies := fs.String("policies", "", "Only push rules with these comma separated policies (e.g., BLOCKLIST)")
+	configName := fs.String("configuration", "", "Name of the Zentral configuration to push the rules to")
+	configID := fs.Int("configuration-id", 0, "ID of the Zentral configuration to push the rules to")
+	deleteMissing := fs.Bool("delete", false, "Delete the Zentral rules missing from the rule set")
+	dryRun := fs.Bool("dry-run", false, "Print the changes without modifying Zentral")
+	identifierValidation := fs.String("identifier-validation", "reject", "How to handle malformed identifiers (reject, warn or off)")
+	skipInvalid := fs.Bool("skip-invalid", false, "Skip and report invalid source records instead of aborting the push")
+	source.RegisterFlags(fs)
+	fs.Usage = pushUsage(fs)
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+	}
+
+	filter, err := rulehelpers.ParseFilter(*ruleTypes, *policies)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid --rule-types or --policies: %v\n", err)
+		os.Exit(1)
+	}
+	validation, err := source.ParseIdentifierValidation(*identifierValidation)
+	if err != nil {
+		println("--identifier-validation must be one of reject, warn or off.")
+		os.Exit(1)
+	}
+	if (*configName == "") == (*configID == 0) {
+		println("Please pass either --configuration or --configuration-id.")
+		os.Exit(1)
+	}
+
+	token := os.Getenv("ZENTRAL_API_KEY")
+	if token == "" {
+		println("Please set ZENTRAL_API_KEY environment variable with your Zentral API token.")
+		os.Exit(1)
+	}
+
+	// Rules are read from Workshop unless another source is selected.
+	var (
+		rules      []*apipb.Rule
+		invalid    rulehelpers.RecordErrors
+		zentralURL string
+	)
+	if _, ok := source.FlagSelected(fs); ok || *sourceName != "" {
+		sel, err := source.Select(fs, *sourceName, fs.Args())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n\n", err)
+			fs.Usage()
+		}
+		if len(sel.Args) < 1 {
+			fs.Usage()
+		}
+		zentralURL = sel.Args[0]
+		_, rules, invalid = readSource(&sel, validation)
+	} else {
+		if fs.NArg() < 2 {
+			fs.Usage()
+		}
+		zentralURL = fs.Arg(1)
+		rules = exportFromWorkshop(fs.Arg(0), *useInsecure)
+	}
+	rules = filter.Apply(rules)
+
+	if !strings.HasPrefix(zentralURL, "http") {
+		zentralURL = "https://" + zentralURL
+	}
+	client := zentral.NewClient(zentralURL, token)
+	ctx := context.Background()
+
+	if *configName != "" {
+		*configID, err = client.ConfigurationID(ctx, *configName)
+		if err != nil {
+			log.Fatalf("Failed to resolve Zentral configuration: %v", err)
+		}
+	}
+
+	plan, err := client.PlanPush(ctx, *configID, rules, invalid, filter, *deleteMissing)
+	if err != nil {
+		log.Fatalf("Failed to plan push: %v", err)
+	}
+	if err := plan.WriteText(os.Stdout); err != nil {
+		log.Fatalf("Failed to write plan: %v", err)
+	}
+	if *dryRun {
+		return
+	}
+
+	// As with imports, any invalid record aborts the push unless skipped.
+	if len(plan.Invalid) > 0 && !*skipInvalid {
+		log.Fatalf("Found %d invalid records, fix them or pass --skip-invalid to push the remaining rules", len(plan.Invalid))
+	}
+
+	applied, err := client.Push(ctx, plan)
+	fmt.Fprintf(os.Stderr, "Applied %d changes to Zentral\n", applied)
+	if err != nil {
+		log.Fatalf("Failed to push rules: %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/northpolesec/santa-rule-importer/morozconfig"
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/northpolesec/santa-rule-importer/workshop"
+
+	syncpb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/sync"
+	svcpb "buf.build/gen/go/northpolesec/workshop-api/grpc/go/workshop/v1/workshopv1grpc"
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// settingsTarget is a Moroz configuration whose settings are migrated to the
+// Workshop sync settings with tag.
+type settingsTarget struct {
+	name     string
+	tag      string
+	settings map[string]any
+}
+
+func settingsUsage(fs *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s settings [OPTIONS] <moroz config file or configs directory> [server]\n", os.Args[0])
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "Report how the settings of a Moroz configuration map to Workshop sync settings\n")
+		fmt.Fprintf(os.Stderr, "and, with --apply, update them in Workshop. The settings of machine-specific\n")
+		fmt.Fprintf(os.Stderr, "configs in a configs directory are applied to the machine with a host tag\n")
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "This tool expects the Workshop API Key to be in the WORKSHOP_API_KEY env var\n")
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintln(os.Stderr, "  Example Usage:")
+		fmt.Fprintf(os.Stderr, "\t%s settings global.toml\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\t%s settings --apply /etc/moroz/configs nps.workshop.cloud\n", os.Args[0])
+		os.Exit(1)
+	}
+}
+
+func runSettings(args []string) {
+	fs := flag.NewFlagSet("settings", flag.ExitOnError)
+	useInsecure := fs.Bool("insecure", false, "Use insecure connection")
+	apply := fs.Bool("apply", false, "Update the sync settings in Workshop instead of only reporting them")
+	tag := fs.String("tag", "", "Apply the settings of a single config file to hosts with this tag instead of globally")
+	fs.Usage = settingsUsage(fs)
+	fs.Parse(args)
+
+	if fs.NArg() < 1 || (*apply && fs.NArg() < 2) {
+		fs.Usage()
+	}
+	path := fs.Arg(0)
+
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() && *tag != "" {
+		println("--tag only applies to a single config file, the machine configs of a configs directory are applied with their host tags.")
+		os.Exit(1)
+	}
+
+	targets, err := readSettingsTargets(path, *tag)
+	if err != nil {
+		log.Fatalf("Failed to read Moroz config: %v", err)
+	}
+
+	var syncSettings []*apipb.SyncSettings
+	var noClientMode []string
+	invalid := 0
+	for _, target := range targets {
+		s, report := morozconfig.MapSettings(target.settings)
+		s.Tag = target.tag
+
+		scope := target.tag
+		if scope == "" {
+			scope = "global"
+		}
+		fmt.Printf("%s (%s)\n", target.name, scope)
+		if err := morozconfig.WriteSettingsReport(os.Stdout, report); err != nil {
+			log.Fatalf("Failed to write settings report: %v", err)
+		}
+		fmt.Println()
+
+		migrated := false
+		for _, setting := range report {
+			migrated = migrated || setting.Migrated()
+			if setting.Err != nil {
+				invalid++
+			}
+		}
+		if migrated {
+			syncSettings = append(syncSettings, s)
+			// Sync settings are replaced as a whole and the client mode
+			// can't be left unset, so it has to come from the config.
+			if s.GetClientMode() == syncpb.ClientMode_UNKNOWN_CLIENT_MODE {
+				noClientMode = append(noClientMode, target.name)
+			}
+		}
+	}
+
+	if !*apply {
+		return
+	}
+	if invalid > 0 {
+		log.Fatalf("Not applying settings: %d invalid settings", invalid)
+	}
+	if len(noClientMode) > 0 {
+		log.Fatalf("Not applying settings: %s set no client_mode, which would reset the client mode in Workshop", strings.Join(noClientMode, ", "))
+	}
+
+	apiKey := os.Getenv("WORKSHOP_API_KEY")
+	if apiKey == "" {
+		println("Please set WORKSHOP_API_KEY environment variable with your API key.")
+		os.Exit(1)
+	}
+
+	conn, err := workshop.Dial(fs.Arg(1), apiKey, *useInsecure)
+	if err != nil {
+		log.Fatalf("Failed to connect to server: %v", err)
+	}
+	defer conn.Close()
+	client := svcpb.NewWorkshopServiceClient(conn)
+
+	for _, s := range syncSettings {
+		_, err := client.UpdateSettings(context.Background(), &apipb.UpdateSettingsRequest{
+			Settings: &apipb.UpdateSettingsRequest_SyncSettings{SyncSettings: s},
+		})
+		if err != nil {
+			log.Fatalf("Failed to update sync settings for %q: %v", s.GetTag(), err)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Updated %d sync settings\n", len(syncSettings))
+}
+
+// readSettingsTargets reads the settings of the Moroz config file at path, to
+// be applied with tag, or of every config in the configs directory at path.
+func readSettingsTargets(path, tag string) ([]settingsTarget, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !fi.IsDir() {
+		config, err := morozconfig.ParseConfigFromFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return []settingsTarget{{name: path, tag: tag, settings: config.Settings}}, nil
+	}
+
+	// Invalid rules don't affect the settings.
+	dir, err := morozconfig.ReadDirectory(path, false)
+	var recordErrs rulehelpers.RecordErrors
+	if err != nil && !errors.As(err, &recordErrs) {
+		return nil, err
+	}
+
+	targets := []settingsTarget{{
+		name:     filepath.Join(path, morozconfig.GlobalConfig),
+		settings: dir.GlobalSettings,
+	}}
+	for _, m := range dir.Machines {
+		targets = append(targets, settingsTarget{
+			name:     filepath.Join(path, m.ID+".toml"),
+			tag:      "host:" + m.ID,
+			settings: m.Settings,
+		})
+	}
+	return targets, nil
+}
@@ -0,0 +1,16 @@
+package main
+
+// Rule sources available to the importer. Each package registers itself with
+// the source package when it is imported; a wrapper binary can add its own
+// sources the same way.
+import (
+	_ "github.com/northpolesec/santa-rule-importer/mobileconfig"
+	_ "github.com/northpolesec/santa-rule-importer/morozconfig"
+	_ "github.com/northpolesec/santa-rule-importer/osquery"
+	_ "github.com/northpolesec/santa-rule-importer/rudolph"
+	_ "github.com/northpolesec/santa-rule-importer/rulesdb"
+	_ "github.com/northpolesec/santa-rule-importer/santactl"
+	_ "github.com/northpolesec/santa-rule-importer/upvote"
+	_ "github.com/northpolesec/santa-rule-importer/workshop"
+	_ "github.com/northpolesec/santa-rule-importer/zentral"
+)
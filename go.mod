@@ -6,9 +6,13 @@ require (
 	buf.build/gen/go/northpolesec/protos/protocolbuffers/go v1.36.5-20250214013108-459ace571a4b.1
 	buf.build/gen/go/northpolesec/workshop-api/grpc/go v1.5.1-20250310185908-3540211763ba.2
 	buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go v1.36.5-20250310185908-3540211763ba.1
+	github.com/mattn/go-sqlite3 v1.14.33
 	github.com/pelletier/go-toml/v2 v2.2.3
 	github.com/shoenig/test v1.12.1
+	golang.org/x/time v0.11.0
 	google.golang.org/grpc v1.71.0
+	google.golang.org/protobuf v1.36.5
+	howett.net/plist v1.0.1
 )
 
 require (
@@ -18,5 +22,4 @@ require (
 	golang.org/x/text v0.21.0 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20250106144421-5f5ef82da422 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f // indirect
-	google.golang.org/protobuf v1.36.5 // indirect
 )
@@ -0,0 +1,7 @@
+// Package importer works out which rules need to be created or updated in a
+// Workshop instance and applies those changes using the Workshop API. The
+// Importer type wraps both steps for callers that don't need to inspect or
+// change the plan in between, and its ImportSource method imports a source
+// the way the command line tool does: checking its records, filtering its
+// rules and journaling the outcome of each one.
+package importer
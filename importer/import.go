@@ -0,0 +1,207 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/northpolesec/santa-rule-importer/internal/journal"
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/northpolesec/santa-rule-importer/source"
+	"github.com/northpolesec/santa-rule-importer/workshop"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// ErrSourceChanged is returned by ImportSource when resuming a journal that
+// was written for a different source, unless ResumeChangedSource is set.
+var ErrSourceChanged = errors.New("the source has changed since the journal was written")
+
+// InvalidRecordsError is returned by ImportSource if the source has invalid
+// records and neither SkipInvalid nor DryRun is set.
+type InvalidRecordsError struct {
+	Records rulehelpers.RecordErrors
+}
+
+func (e *InvalidRecordsError) Error() string {
+	return fmt.Sprintf("found %d invalid records", len(e.Records))
+}
+
+// Importer imports rules into the Workshop instance behind Client. The zero
+// value of every field other than Client is usable and creates every rule.
+type Importer struct {
+	// Client is usually a WorkshopServiceClient created from a connection
+	// returned by workshop.Dial.
+	Client workshop.RuleClient
+
+	// Sync compares the rules against those already in Workshop so that only
+	// missing rules are created and changed rules updated. Otherwise every
+	// rule is created.
+	Sync bool
+
+	// DryRun plans the import without applying it. The plan is always made
+	// against the existing rules, as with Sync.
+	DryRun bool
+
+	// OnPlan, if set, is called with the plan before it is applied, for
+	// reporting or to remove rules from it with Plan.Filter.
+	OnPlan func(*Plan)
+
+	// Filter selects the rules of a source that ImportSource imports.
+	Filter rulehelpers.Filter
+
+	// IdentifierValidation is how ImportSource handles identifiers that don't
+	// have the expected shape for their rule type. The zero value rejects
+	// them as invalid records.
+	IdentifierValidation source.IdentifierValidation
+
+	// SkipInvalid makes ImportSource import the remaining rules of a source
+	// with invalid records, instead of returning an InvalidRecordsError.
+	SkipInvalid bool
+
+	// Journal, if set, is the path of a journal in which ImportSource records
+	// the outcome of each rule, so that an interrupted import can be resumed.
+	Journal string
+
+	// Resume skips the rules the Journal records as already applied.
+	// ResumeChangedSource allows resuming a journal written for a different
+	// source, in which case only rules with unchanged contents are skipped.
+	Resume              bool
+	ResumeChangedSource bool
+
+	// Logf, if set, is called with the invalid records and warnings that
+	// ImportSource reports without stopping.
+	Logf func(format string, args ...any)
+
+	// Options control how the plan is applied.
+	Options
+}
+
+// Plan works out the changes needed to import rules.
+func (im *Importer) Plan(ctx context.Context, rules []*apipb.Rule) (*Plan, error) {
+	if !im.Sync && !im.DryRun {
+		return CreateAll(rules), nil
+	}
+
+	existing, err := workshop.ListRules(ctx, im.Client)
+	if err != nil {
+		return nil, err
+	}
+	return Diff(rules, existing), nil
+}
+
+// Apply applies a plan made by Plan. See the package-level Apply function.
+func (im *Importer) Apply(ctx context.Context, plan *Plan) Result {
+	return Apply(ctx, im.Client, plan, im.Options)
+}
+
+// Import plans the import of rules, calls OnPlan and, unless DryRun is set,
+// applies the plan. An error is only returned if the plan could not be made;
+// rules that could not be applied are reported in the Result.
+func (im *Importer) Import(ctx context.Context, rules []*apipb.Rule) (*Plan, Result, error) {
+	plan, err := im.Plan(ctx, rules)
+	if err != nil {
+		return nil, Result{}, err
+	}
+
+	if im.OnPlan != nil {
+		im.OnPlan(plan)
+	}
+	if im.DryRun {
+		return plan, Result{}, nil
+	}
+
+	return plan, im.Apply(ctx, plan), nil
+}
+
+// ImportSource reads the rules of src, checks their identifiers and imports
+// the rules selected by Filter like Import, recording their outcomes in the
+// Journal. The source's invalid records are reported in the plan. Sources that
+// record their progress are committed once every rule has been applied, so
+// that failed rules are read again by the next import; if that fails, the
+// plan and result are returned along with the error. Any other error means
+// nothing was applied.
+func (im *Importer) ImportSource(ctx context.Context, src source.Source) (*Plan, Result, error) {
+	// Identifiers are checked before filtering so that they are located by
+	// the source's own records.
+	rules, recordErrs, warnings, err := source.Read(ctx, src, im.IdentifierValidation)
+	if err != nil {
+		return nil, Result{}, err
+	}
+	for _, warning := range warnings {
+		im.logf("Warning: malformed identifier %v", warning)
+	}
+	for _, recordErr := range recordErrs {
+		im.logf("Invalid record %v", recordErr)
+	}
+	rules = im.Filter.Apply(rules)
+
+	// A dry run still makes the plan so the invalid records can be reviewed.
+	if len(recordErrs) > 0 && !im.SkipInvalid && !im.DryRun {
+		return nil, Result{}, &InvalidRecordsError{Records: recordErrs}
+	}
+
+	plan, err := im.Plan(ctx, rules)
+	if err != nil {
+		return nil, Result{}, err
+	}
+	plan.Invalid = append(InvalidRecords(recordErrs), plan.Invalid...)
+
+	if im.DryRun {
+		if im.OnPlan != nil {
+			im.OnPlan(plan)
+		}
+		return plan, Result{}, nil
+	}
+
+	opts := im.Options
+	skipped := 0
+	if im.Journal != "" {
+		jrnl, err := journal.Open(im.Journal, rules, im.Resume)
+		if err != nil {
+			return nil, Result{}, err
+		}
+		defer jrnl.Close()
+
+		if im.Resume {
+			if jrnl.SourceChanged {
+				if !im.ResumeChangedSource {
+					return nil, Result{}, ErrSourceChanged
+				}
+				im.logf("Warning: the source has changed since the journal was written, only rules with unchanged contents will be skipped")
+			}
+			skipped = plan.Filter(func(rule *apipb.Rule) bool { return !jrnl.Applied(rule) })
+		}
+
+		onResult := opts.OnResult
+		opts.OnResult = func(r RuleResult) {
+			if err := jrnl.Record(r.Rule, string(r.Action), r.Err); err != nil {
+				im.logf("Failed to record rule %s in journal: %v", r.Rule.GetIdentifier(), err)
+			}
+			if onResult != nil {
+				onResult(r)
+			}
+		}
+	}
+
+	if im.OnPlan != nil {
+		im.OnPlan(plan)
+	}
+	result := Apply(ctx, im.Client, plan, opts)
+	result.Skipped = skipped
+
+	if committer, ok := src.(source.Committer); ok {
+		if result.Failed > 0 {
+			im.logf("Not recording the source's progress since %d rules failed", result.Failed)
+		} else if err := committer.Commit(); err != nil {
+			return plan, result, fmt.Errorf("failed to record the source's progress: %w", err)
+		}
+	}
+	return plan, result, nil
+}
+
+func (im *Importer) logf(format string, args ...any) {
+	if im.Logf != nil {
+		im.Logf(format, args...)
+	}
+}
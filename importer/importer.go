@@ -0,0 +1,469 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/northpolesec/santa-rule-importer/workshop"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	syncpb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/sync"
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// Key uniquely identifies a rule in a Workshop instance. Rules with the same
+// rule type and identifier but different tags can coexist.
+type Key = rulehelpers.Key
+
+// KeyOf returns the Key for a rule.
+func KeyOf(rule *apipb.Rule) Key {
+	return rulehelpers.KeyOf(rule)
+}
+
+// Update pairs a rule that already exists in Workshop with the source rule
+// that should replace it.
+type Update struct {
+	Existing *apipb.Rule
+	Rule     *apipb.Rule
+}
+
+// Changes returns the names of the fields that differ between the existing
+// rule and its replacement.
+func (u Update) Changes() []string {
+	var changes []string
+	if u.Existing.GetPolicy() != u.Rule.GetPolicy() {
+		changes = append(changes, "policy")
+	}
+	if u.Existing.GetCustomMsg() != u.Rule.GetCustomMsg() {
+		changes = append(changes, "custom_msg")
+	}
+	if u.Existing.GetCustomUrl() != u.Rule.GetCustomUrl() {
+		changes = append(changes, "custom_url")
+	}
+	if u.Existing.GetComment() != u.Rule.GetComment() {
+		changes = append(changes, "comment")
+	}
+	return changes
+}
+
+// Invalid is a source record that cannot be imported.
+type Invalid struct {
+	Location   string
+	Identifier string
+	Reason     string
+}
+
+// InvalidRecords converts the records a parser could not convert into entries
+// for Plan.Invalid.
+func InvalidRecords(errs rulehelpers.RecordErrors) []Invalid {
+	invalid := make([]Invalid, 0, len(errs))
+	for _, err := range errs {
+		invalid = append(invalid, Invalid{
+			Location:   err.Location,
+			Identifier: err.Identifier,
+			Reason:     err.Err.Error(),
+		})
+	}
+	return invalid
+}
+
+// Plan describes the changes needed to import a set of source rules.
+type Plan struct {
+	Create    []*apipb.Rule
+	Update    []Update
+	Unchanged []*apipb.Rule
+	Invalid   []Invalid
+}
+
+// Filter removes the rules to create or update for which keep returns false,
+// returning the number of rules removed.
+func (p *Plan) Filter(keep func(rule *apipb.Rule) bool) int {
+	removed := 0
+
+	create := p.Create[:0:0]
+	for _, rule := range p.Create {
+		if keep(rule) {
+			create = append(create, rule)
+		} else {
+			removed++
+		}
+	}
+	p.Create = create
+
+	update := p.Update[:0:0]
+	for _, u := range p.Update {
+		if keep(u.Rule) {
+			update = append(update, u)
+		} else {
+			removed++
+		}
+	}
+	p.Update = update
+
+	return removed
+}
+
+// validate returns the reason a rule cannot be imported, or an empty string if
+// it can.
+func validate(rule *apipb.Rule) string {
+	switch {
+	case rule.GetIdentifier() == "":
+		return "missing identifier"
+	case rule.GetRuleType() == syncpb.RuleType_RULETYPE_UNKNOWN:
+		return "unknown rule type"
+	case rule.GetPolicy() == syncpb.Policy_POLICY_UNKNOWN:
+		return "unknown policy"
+	}
+	return ""
+}
+
+// CreateAll returns a plan that creates every rule without consulting the
+// rules already in Workshop.
+func CreateAll(rules []*apipb.Rule) *Plan {
+	return &Plan{Create: rules}
+}
+
+// Diff compares the source rules against the rules that already exist in
+// Workshop. Missing rules are created and rules whose policy, custom message,
+// custom URL or comment differ are updated. Rules without an identifier, rule
+// type or policy are reported as invalid. If the source contains the same key
+// more than once the last occurrence wins.
+func Diff(source, existing []*apipb.Rule) *Plan {
+	existingByKey := make(map[Key]*apipb.Rule, len(existing))
+	for _, rule := range existing {
+		existingByKey[KeyOf(rule)] = rule
+	}
+
+	plan := &Plan{}
+
+	// Collapse duplicate source rules, keeping the position of the first
+	// occurrence so the plan follows the source order.
+	var keys []Key
+	sourceByKey := make(map[Key]*apipb.Rule, len(source))
+	for i, rule := range source {
+		if reason := validate(rule); reason != "" {
+			plan.Invalid = append(plan.Invalid, Invalid{
+				Location:   fmt.Sprintf("rule %d", i),
+				Identifier: rule.GetIdentifier(),
+				Reason:     reason,
+			})
+			continue
+		}
+
+		key := KeyOf(rule)
+		if _, ok := sourceByKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		sourceByKey[key] = rule
+	}
+
+	for _, key := range keys {
+		rule := sourceByKey[key]
+		current, ok := existingByKey[key]
+		switch {
+		case !ok:
+			plan.Create = append(plan.Create, rule)
+		case differs(current, rule):
+			plan.Update = append(plan.Update, Update{Existing: current, Rule: rule})
+		default:
+			plan.Unchanged = append(plan.Unchanged, rule)
+		}
+	}
+
+	return plan
+}
+
+// differs reports whether any of the mutable fields of two rules differ.
+func differs(a, b *apipb.Rule) bool {
+	return len(Update{Existing: a, Rule: b}.Changes()) > 0
+}
+
+// Action is the change made to Workshop for a single rule.
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	// ActionDelete is reported for an update that deleted the existing rule
+	// but could neither create its replacement nor restore the original.
+	ActionDelete Action = "delete"
+)
+
+// RuleResult is the outcome of applying a single rule.
+type RuleResult struct {
+	Action Action
+	Rule   *apipb.Rule
+	Err    error
+
+	// Attempts is the number of times the last request for this rule was
+	// sent.
+	Attempts int
+}
+
+// Exhausted reports whether the rule failed with a retryable error after
+// every attempt was used, as opposed to being rejected outright.
+func (r RuleResult) Exhausted() bool {
+	return r.Err != nil && retryable(r.Err)
+}
+
+// Result summarizes the outcome of applying a plan.
+type Result struct {
+	Created   int
+	Updated   int
+	Unchanged int
+	Invalid   int
+
+	// Failed is the number of rules that could not be applied. Each failure is
+	// either Rejected with a permanent error (e.g. InvalidArgument) or
+	// Exhausted all of its attempts on retryable errors.
+	Failed    int
+	Rejected  int
+	Exhausted int
+
+	// Deleted is the number of failed updates that left the rule deleted from
+	// Workshop. They are counted in Failed too.
+	Deleted int
+
+	// Skipped is the number of rules a resumed Importer.ImportSource left out
+	// of the plan since its journal records them as already applied.
+	Skipped int
+
+	// Rules holds the outcome of every create followed by every update, each
+	// in the order they appear in the plan.
+	Rules []RuleResult
+}
+
+// Options control how a plan is applied.
+type Options struct {
+	// Concurrency is the number of rules applied in parallel. Values below 1
+	// are treated as 1.
+	Concurrency int
+
+	// RateLimit is the maximum number of requests per second sent to Workshop.
+	// Zero means no limit.
+	RateLimit float64
+
+	// MaxAttempts is the number of times a request is sent before giving up
+	// on a retryable error. Values below 1 are treated as 1.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry. It doubles on each
+	// further retry up to MaxBackoff, with jitter applied. Zero values use
+	// DefaultInitialBackoff and DefaultMaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Timeout bounds each individual request. Zero means no timeout.
+	Timeout time.Duration
+
+	// OnResult, if set, is called as soon as each rule has been applied. It is
+	// called from multiple goroutines when Concurrency is above 1.
+	OnResult func(RuleResult)
+}
+
+const (
+	DefaultInitialBackoff = 500 * time.Millisecond
+	DefaultMaxBackoff     = 30 * time.Second
+)
+
+// retryable reports whether err is a transient gRPC failure worth retrying.
+func retryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+// Apply creates and updates rules in Workshop according to the plan, using a
+// pool of opts.Concurrency workers. Requests that fail with Unavailable,
+// ResourceExhausted or DeadlineExceeded are retried with backoff. The Workshop
+// API has no way to modify a rule in place, so updates delete the existing rule
+// and create its replacement, restoring the existing rule if the replacement
+// can't be created. Failures are recorded in the result rather than aborting
+// the import.
+func Apply(ctx context.Context, client workshop.RuleClient, plan *Plan, opts Options) Result {
+	result := Result{
+		Unchanged: len(plan.Unchanged),
+		Invalid:   len(plan.Invalid),
+		Rules:     make([]RuleResult, 0, len(plan.Create)+len(plan.Update)),
+	}
+
+	// existing holds the rule being replaced by each update, indexed like
+	// result.Rules.
+	existing := make([]*apipb.Rule, len(plan.Create), len(plan.Create)+len(plan.Update))
+	for _, rule := range plan.Create {
+		result.Rules = append(result.Rules, RuleResult{Action: ActionCreate, Rule: rule})
+	}
+	for _, u := range plan.Update {
+		result.Rules = append(result.Rules, RuleResult{Action: ActionUpdate, Rule: u.Rule})
+		existing = append(existing, u.Existing)
+	}
+
+	s := newSender(client, opts)
+
+	workers := max(opts.Concurrency, 1)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				r := &result.Rules[i]
+				if r.Action == ActionUpdate {
+					var deleted bool
+					r.Attempts, deleted, r.Err = s.update(ctx, existing[i], r.Rule)
+					if deleted {
+						r.Action = ActionDelete
+					}
+				} else {
+					r.Attempts, r.Err = s.create(ctx, r.Rule)
+				}
+				if opts.OnResult != nil {
+					opts.OnResult(*r)
+				}
+			}
+		}()
+	}
+
+	for i := range result.Rules {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, r := range result.Rules {
+		if r.Action == ActionDelete {
+			result.Deleted++
+		}
+		switch {
+		case r.Exhausted():
+			result.Failed++
+			result.Exhausted++
+		case r.Err != nil:
+			result.Failed++
+			result.Rejected++
+		case r.Action == ActionUpdate:
+			result.Updated++
+		default:
+			result.Created++
+		}
+	}
+
+	return result
+}
+
+// sender sends requests to Workshop, applying the rate limit, timeouts and
+// retries configured in Options.
+type sender struct {
+	client  workshop.RuleClient
+	opts    Options
+	limiter *rate.Limiter
+}
+
+func newSender(client workshop.RuleClient, opts Options) *sender {
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = DefaultInitialBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	opts.MaxAttempts = max(opts.MaxAttempts, 1)
+
+	limiter := rate.NewLimiter(rate.Inf, 1)
+	if opts.RateLimit > 0 {
+		limiter = rate.NewLimiter(rate.Limit(opts.RateLimit), 1)
+	}
+
+	return &sender{client: client, opts: opts, limiter: limiter}
+}
+
+// call sends a request using fn, retrying retryable errors with exponential
+// backoff. It returns the number of attempts made and the last error.
+func (s *sender) call(ctx context.Context, fn func(ctx context.Context) error) (int, error) {
+	backoff := s.opts.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		if err := s.limiter.Wait(ctx); err != nil {
+			return attempt - 1, err
+		}
+
+		err := s.attempt(ctx, fn)
+		if err == nil || !retryable(err) || attempt >= s.opts.MaxAttempts {
+			return attempt, err
+		}
+
+		// Wait a random half to all of the backoff so that workers
+		// retrying at the same time spread out.
+		delay := backoff/2 + rand.N(backoff/2+1)
+		select {
+		case <-ctx.Done():
+			return attempt, err
+		case <-time.After(delay):
+		}
+		backoff = min(backoff*2, s.opts.MaxBackoff)
+	}
+}
+
+// attempt sends a single request, bounded by the configured timeout.
+func (s *sender) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.opts.Timeout)
+		defer cancel()
+	}
+	return fn(ctx)
+}
+
+func (s *sender) create(ctx context.Context, rule *apipb.Rule) (int, error) {
+	return s.call(ctx, func(ctx context.Context) error {
+		_, err := s.client.CreateRule(ctx, &apipb.CreateRuleRequest{Rule: rule})
+		return err
+	})
+}
+
+// update replaces existing with rule. If the replacement can't be created, the
+// existing rule is created again; deleted reports whether that failed too,
+// leaving neither rule in Workshop.
+func (s *sender) update(ctx context.Context, existing, rule *apipb.Rule) (attempts int, deleted bool, err error) {
+	sent := 0
+	attempts, err = s.call(ctx, func(ctx context.Context) error {
+		sent++
+		_, err := s.client.DeleteRule(ctx, &apipb.DeleteRuleRequest{
+			RuleId: proto.String(existing.GetRuleId()),
+		})
+		// A retry after a timeout may find that the first request deleted
+		// the rule after all.
+		if sent > 1 && status.Code(err) == codes.NotFound {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return attempts, false, fmt.Errorf("failed to delete existing rule: %w", err)
+	}
+
+	attempts, err = s.create(ctx, rule)
+	if err == nil {
+		return attempts, false, nil
+	}
+
+	// Restore the existing rule even if the import was cancelled, so that a
+	// failed update leaves Workshop as it was.
+	restore := proto.Clone(existing).(*apipb.Rule)
+	restore.RuleId = ""
+	if _, restoreErr := s.create(context.WithoutCancel(ctx), restore); restoreErr != nil {
+		return attempts, true, fmt.Errorf("failed to create replacement rule: %w; the existing rule was deleted and could not be restored: %v", err, restoreErr)
+	}
+	return attempts, false, fmt.Errorf("failed to create replacement rule, restored the existing rule: %w", err)
+}
@@ -0,0 +1,585 @@
+package importer_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shoenig/test"
+	"github.com/shoenig/test/must"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/northpolesec/santa-rule-importer/importer"
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+
+	syncpb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/sync"
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// fakeClient lists the existing rules and records the rules created and
+// deleted through it.
+type fakeClient struct {
+	mu       sync.Mutex
+	existing []*apipb.Rule
+	created  []*apipb.Rule
+	deleted  []string
+	failFor  map[string]bool
+}
+
+func (f *fakeClient) ListRules(ctx context.Context, in *apipb.ListRulesRequest, opts ...grpc.CallOption) (*apipb.ListRulesResponse, error) {
+	return &apipb.ListRulesResponse{Rules: f.existing}, nil
+}
+
+func (f *fakeClient) CreateRule(ctx context.Context, in *apipb.CreateRuleRequest, opts ...grpc.CallOption) (*apipb.CreateRuleResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.failFor[in.GetRule().GetIdentifier()] {
+		return nil, errors.New("rejected")
+	}
+	f.created = append(f.created, in.GetRule())
+	return &apipb.CreateRuleResponse{}, nil
+}
+
+func (f *fakeClient) DeleteRule(ctx context.Context, in *apipb.DeleteRuleRequest, opts ...grpc.CallOption) (*apipb.DeleteRuleResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.deleted = append(f.deleted, in.GetRuleId())
+	return &apipb.DeleteRuleResponse{}, nil
+}
+
+func rule(id string, policy syncpb.Policy, msg string) *apipb.Rule {
+	return &apipb.Rule{
+		RuleType:   syncpb.RuleType_SIGNINGID,
+		Policy:     policy,
+		Identifier: id,
+		CustomMsg:  msg,
+	}
+}
+
+func TestDiff(t *testing.T) {
+	existing := []*apipb.Rule{
+		{RuleId: "r1", RuleType: syncpb.RuleType_SIGNINGID, Policy: syncpb.Policy_BLOCKLIST, Identifier: "platform:com.apple.osascript"},
+		{RuleId: "r2", RuleType: syncpb.RuleType_SIGNINGID, Policy: syncpb.Policy_ALLOWLIST, Identifier: "EQHXZ8M8AV:com.google.Chrome"},
+		{RuleId: "r3", RuleType: syncpb.RuleType_TEAMID, Policy: syncpb.Policy_ALLOWLIST, Identifier: "EQHXZ8M8AV"},
+	}
+
+	source := []*apipb.Rule{
+		rule("platform:com.apple.osascript", syncpb.Policy_BLOCKLIST, ""),
+		rule("EQHXZ8M8AV:com.google.Chrome", syncpb.Policy_ALLOWLIST, "Chrome is allowed"),
+		rule("platform:com.apple.osacompile", syncpb.Policy_BLOCKLIST, ""),
+		// Same identifier as the existing TEAMID rule but a different type.
+		rule("EQHXZ8M8AV", syncpb.Policy_BLOCKLIST, ""),
+	}
+
+	plan := importer.Diff(source, existing)
+
+	must.Eq(t, 2, len(plan.Create))
+	test.Eq(t, "platform:com.apple.osacompile", plan.Create[0].GetIdentifier())
+	test.Eq(t, syncpb.RuleType_SIGNINGID, plan.Create[1].GetRuleType())
+	test.Eq(t, "EQHXZ8M8AV", plan.Create[1].GetIdentifier())
+
+	must.Eq(t, 1, len(plan.Update))
+	test.Eq(t, "r2", plan.Update[0].Existing.GetRuleId())
+	test.Eq(t, "Chrome is allowed", plan.Update[0].Rule.GetCustomMsg())
+
+	must.Eq(t, 1, len(plan.Unchanged))
+	test.Eq(t, "platform:com.apple.osascript", plan.Unchanged[0].GetIdentifier())
+}
+
+func TestDiffDuplicateSourceRules(t *testing.T) {
+	source := []*apipb.Rule{
+		rule("platform:com.apple.osascript", syncpb.Policy_ALLOWLIST, ""),
+		rule("platform:com.apple.osacompile", syncpb.Policy_BLOCKLIST, ""),
+		rule("platform:com.apple.osascript", syncpb.Policy_BLOCKLIST, ""),
+	}
+
+	plan := importer.Diff(source, nil)
+
+	must.Eq(t, 2, len(plan.Create))
+	test.Eq(t, "platform:com.apple.osascript", plan.Create[0].GetIdentifier())
+	test.Eq(t, syncpb.Policy_BLOCKLIST, plan.Create[0].GetPolicy())
+	test.Eq(t, "platform:com.apple.osacompile", plan.Create[1].GetIdentifier())
+}
+
+func TestApply(t *testing.T) {
+	client := &fakeClient{failFor: map[string]bool{"platform:com.apple.bad": true}}
+
+	plan := &importer.Plan{
+		Create: []*apipb.Rule{
+			rule("platform:com.apple.osacompile", syncpb.Policy_BLOCKLIST, ""),
+			rule("platform:com.apple.bad", syncpb.Policy_BLOCKLIST, ""),
+		},
+		Update: []importer.Update{{
+			Existing: &apipb.Rule{RuleId: "r2", Identifier: "EQHXZ8M8AV:com.google.Chrome"},
+			Rule:     rule("EQHXZ8M8AV:com.google.Chrome", syncpb.Policy_ALLOWLIST, "Chrome is allowed"),
+		}},
+		Unchanged: []*apipb.Rule{
+			rule("platform:com.apple.osascript", syncpb.Policy_BLOCKLIST, ""),
+		},
+	}
+
+	result := importer.Apply(context.Background(), client, plan, importer.Options{})
+
+	test.Eq(t, 1, result.Created)
+	test.Eq(t, 1, result.Updated)
+	test.Eq(t, 1, result.Unchanged)
+	test.Eq(t, 1, result.Failed)
+	test.Eq(t, 1, result.Rejected)
+
+	must.Eq(t, 3, len(result.Rules))
+	test.Eq(t, importer.ActionCreate, result.Rules[0].Action)
+	test.NoError(t, result.Rules[0].Err)
+	test.Eq(t, "platform:com.apple.bad", result.Rules[1].Rule.GetIdentifier())
+	test.ErrorContains(t, result.Rules[1].Err, "rejected")
+	test.Eq(t, importer.ActionUpdate, result.Rules[2].Action)
+	test.NoError(t, result.Rules[2].Err)
+
+	test.Eq(t, []string{"r2"}, client.deleted)
+	must.Eq(t, 2, len(client.created))
+	test.Eq(t, "Chrome is allowed", client.created[1].GetCustomMsg())
+}
+
+func TestApplyConcurrentResultsInOrder(t *testing.T) {
+	client := &fakeClient{failFor: map[string]bool{}}
+
+	plan := &importer.Plan{}
+	for i := range 100 {
+		id := fmt.Sprintf("platform:com.example.app%d", i)
+		if i%7 == 0 {
+			client.failFor[id] = true
+		}
+		plan.Create = append(plan.Create, rule(id, syncpb.Policy_ALLOWLIST, ""))
+	}
+
+	result := importer.Apply(context.Background(), client, plan, importer.Options{Concurrency: 8})
+
+	test.Eq(t, 85, result.Created)
+	test.Eq(t, 15, result.Failed)
+	test.Eq(t, 85, len(client.created))
+
+	must.Eq(t, 100, len(result.Rules))
+	for i, r := range result.Rules {
+		test.Eq(t, fmt.Sprintf("platform:com.example.app%d", i), r.Rule.GetIdentifier())
+		test.Eq(t, i%7 == 0, r.Err != nil)
+	}
+}
+
+func TestApplyRateLimit(t *testing.T) {
+	client := &fakeClient{}
+
+	plan := &importer.Plan{}
+	for i := range 5 {
+		plan.Create = append(plan.Create, rule(fmt.Sprintf("platform:com.example.app%d", i), syncpb.Policy_ALLOWLIST, ""))
+	}
+
+	start := time.Now()
+	result := importer.Apply(context.Background(), client, plan, importer.Options{Concurrency: 5, RateLimit: 50})
+
+	test.Eq(t, 5, result.Created)
+	// The first request is sent immediately and the rest are spaced 20ms apart.
+	test.GreaterEq(t, 80*time.Millisecond, time.Since(start))
+}
+
+func TestDiffInvalidRules(t *testing.T) {
+	source := []*apipb.Rule{
+		rule("platform:com.apple.osascript", syncpb.Policy_BLOCKLIST, ""),
+		rule("", syncpb.Policy_BLOCKLIST, ""),
+		rule("platform:com.apple.osacompile", syncpb.Policy_POLICY_UNKNOWN, ""),
+	}
+
+	plan := importer.Diff(source, nil)
+
+	must.Eq(t, 1, len(plan.Create))
+	must.Eq(t, 2, len(plan.Invalid))
+	test.Eq(t, "rule 1", plan.Invalid[0].Location)
+	test.Eq(t, "missing identifier", plan.Invalid[0].Reason)
+	test.Eq(t, "rule 2", plan.Invalid[1].Location)
+	test.Eq(t, "platform:com.apple.osacompile", plan.Invalid[1].Identifier)
+	test.Eq(t, "unknown policy", plan.Invalid[1].Reason)
+}
+
+func TestInvalidRecords(t *testing.T) {
+	invalid := importer.InvalidRecords(rulehelpers.RecordErrors{{
+		Location:   "rules.csv:3",
+		Identifier: "abc",
+		Err:        errors.New(`unknown policy type: "DENY"`),
+	}})
+
+	test.Eq(t, []importer.Invalid{{
+		Location:   "rules.csv:3",
+		Identifier: "abc",
+		Reason:     `unknown policy type: "DENY"`,
+	}}, invalid)
+}
+
+// flakyClient fails CreateRule with the queued errors for an identifier before
+// succeeding.
+type flakyClient struct {
+	fakeClient
+	errs  map[string][]error
+	calls map[string]int
+}
+
+func (f *flakyClient) CreateRule(ctx context.Context, in *apipb.CreateRuleRequest, opts ...grpc.CallOption) (*apipb.CreateRuleResponse, error) {
+	f.mu.Lock()
+	id := in.GetRule().GetIdentifier()
+	f.calls[id]++
+	if errs := f.errs[id]; len(errs) > 0 {
+		f.errs[id] = errs[1:]
+		f.mu.Unlock()
+		return nil, errs[0]
+	}
+	f.mu.Unlock()
+
+	return f.fakeClient.CreateRule(ctx, in, opts...)
+}
+
+func TestApplyRetries(t *testing.T) {
+	client := &flakyClient{
+		calls: map[string]int{},
+		errs: map[string][]error{
+			"platform:com.example.flaky": {
+				status.Error(codes.Unavailable, "connection reset"),
+				status.Error(codes.ResourceExhausted, "slow down"),
+			},
+			"platform:com.example.down": {
+				status.Error(codes.Unavailable, "connection reset"),
+				status.Error(codes.Unavailable, "connection reset"),
+				status.Error(codes.DeadlineExceeded, "timed out"),
+			},
+			"platform:com.example.invalid": {
+				status.Error(codes.InvalidArgument, "bad identifier"),
+			},
+		},
+	}
+
+	plan := &importer.Plan{
+		Create: []*apipb.Rule{
+			rule("platform:com.example.flaky", syncpb.Policy_ALLOWLIST, ""),
+			rule("platform:com.example.down", syncpb.Policy_ALLOWLIST, ""),
+			rule("platform:com.example.invalid", syncpb.Policy_ALLOWLIST, ""),
+		},
+	}
+
+	result := importer.Apply(context.Background(), client, plan, importer.Options{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     2 * time.Millisecond,
+	})
+
+	test.Eq(t, 1, result.Created)
+	test.Eq(t, 2, result.Failed)
+	test.Eq(t, 1, result.Rejected)
+	test.Eq(t, 1, result.Exhausted)
+
+	must.Eq(t, 3, len(result.Rules))
+
+	test.NoError(t, result.Rules[0].Err)
+	test.Eq(t, 3, result.Rules[0].Attempts)
+
+	test.Eq(t, codes.DeadlineExceeded, status.Code(result.Rules[1].Err))
+	test.True(t, result.Rules[1].Exhausted())
+	test.Eq(t, 3, result.Rules[1].Attempts)
+
+	test.Eq(t, codes.InvalidArgument, status.Code(result.Rules[2].Err))
+	test.False(t, result.Rules[2].Exhausted())
+	test.Eq(t, 1, result.Rules[2].Attempts)
+	test.Eq(t, 1, client.calls["platform:com.example.invalid"])
+}
+
+// updateClient fails DeleteRule with deleteErrs, keyed by rule ID, in turn
+// and CreateRule for rules whose custom message is in failMsgs.
+type updateClient struct {
+	fakeClient
+	deleteErrs map[string][]error
+	failMsgs   map[string]bool
+}
+
+func (u *updateClient) CreateRule(ctx context.Context, in *apipb.CreateRuleRequest, opts ...grpc.CallOption) (*apipb.CreateRuleResponse, error) {
+	if u.failMsgs[in.GetRule().GetCustomMsg()] {
+		return nil, status.Error(codes.InvalidArgument, "rejected")
+	}
+	return u.fakeClient.CreateRule(ctx, in, opts...)
+}
+
+func (u *updateClient) DeleteRule(ctx context.Context, in *apipb.DeleteRuleRequest, opts ...grpc.CallOption) (*apipb.DeleteRuleResponse, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if errs := u.deleteErrs[in.GetRuleId()]; len(errs) > 0 {
+		u.deleteErrs[in.GetRuleId()] = errs[1:]
+		return nil, errs[0]
+	}
+	u.deleted = append(u.deleted, in.GetRuleId())
+	return &apipb.DeleteRuleResponse{}, nil
+}
+
+func TestApplyUpdateFailures(t *testing.T) {
+	client := &updateClient{
+		deleteErrs: map[string][]error{
+			// The first delete timed out after deleting the rule.
+			"r1": {status.Error(codes.DeadlineExceeded, "timed out"), status.Error(codes.NotFound, "no such rule")},
+		},
+		failMsgs: map[string]bool{"bad replacement": true, "bad original": true},
+	}
+
+	existing := func(id, ruleID, msg string) *apipb.Rule {
+		r := rule(id, syncpb.Policy_BLOCKLIST, msg)
+		r.RuleId = ruleID
+		return r
+	}
+	plan := &importer.Plan{
+		Update: []importer.Update{
+			{Existing: existing("platform:com.example.one", "r1", ""), Rule: rule("platform:com.example.one", syncpb.Policy_ALLOWLIST, "")},
+			{Existing: existing("platform:com.example.two", "r2", "original"), Rule: rule("platform:com.example.two", syncpb.Policy_ALLOWLIST, "bad replacement")},
+			{Existing: existing("platform:com.example.three", "r3", "bad original"), Rule: rule("platform:com.example.three", syncpb.Policy_ALLOWLIST, "bad replacement")},
+		},
+	}
+
+	result := importer.Apply(context.Background(), client, plan, importer.Options{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+	})
+
+	test.Eq(t, 1, result.Updated)
+	test.Eq(t, 2, result.Failed)
+	test.Eq(t, 1, result.Deleted)
+	must.Eq(t, 3, len(result.Rules))
+
+	test.NoError(t, result.Rules[0].Err)
+
+	// The existing rule is restored when its replacement is rejected.
+	test.Eq(t, importer.ActionUpdate, result.Rules[1].Action)
+	test.ErrorContains(t, result.Rules[1].Err, "restored the existing rule")
+	test.Eq(t, codes.InvalidArgument, status.Code(result.Rules[1].Err))
+
+	// If that fails too, the rule is reported as deleted.
+	test.Eq(t, importer.ActionDelete, result.Rules[2].Action)
+	test.ErrorContains(t, result.Rules[2].Err, "could not be restored")
+
+	test.Eq(t, []string{"r2", "r3"}, client.deleted)
+	must.Eq(t, 2, len(client.created))
+	test.Eq(t, "platform:com.example.one", client.created[0].GetIdentifier())
+	test.Eq(t, "original", client.created[1].GetCustomMsg())
+	test.Eq(t, "", client.created[1].GetRuleId())
+}
+
+// slowClient blocks CreateRule until the request context is done.
+type slowClient struct {
+	fakeClient
+}
+
+func (s *slowClient) CreateRule(ctx context.Context, in *apipb.CreateRuleRequest, opts ...grpc.CallOption) (*apipb.CreateRuleResponse, error) {
+	<-ctx.Done()
+	return nil, status.FromContextError(ctx.Err()).Err()
+}
+
+func TestApplyTimeout(t *testing.T) {
+	plan := &importer.Plan{
+		Create: []*apipb.Rule{rule("platform:com.example.slow", syncpb.Policy_ALLOWLIST, "")},
+	}
+
+	result := importer.Apply(context.Background(), &slowClient{}, plan, importer.Options{
+		MaxAttempts:    2,
+		InitialBackoff: time.Millisecond,
+		Timeout:        10 * time.Millisecond,
+	})
+
+	test.Eq(t, 1, result.Exhausted)
+	test.Eq(t, 2, result.Rules[0].Attempts)
+	test.Eq(t, codes.DeadlineExceeded, status.Code(result.Rules[0].Err))
+}
+
+func TestPlanFilter(t *testing.T) {
+	plan := &importer.Plan{
+		Create: []*apipb.Rule{
+			rule("platform:com.example.a", syncpb.Policy_ALLOWLIST, ""),
+			rule("platform:com.example.b", syncpb.Policy_ALLOWLIST, ""),
+		},
+		Update: []importer.Update{{
+			Existing: rule("platform:com.example.c", syncpb.Policy_BLOCKLIST, ""),
+			Rule:     rule("platform:com.example.c", syncpb.Policy_ALLOWLIST, ""),
+		}},
+	}
+
+	removed := plan.Filter(func(r *apipb.Rule) bool {
+		return r.GetIdentifier() == "platform:com.example.b"
+	})
+
+	test.Eq(t, 2, removed)
+	must.Eq(t, 1, len(plan.Create))
+	test.Eq(t, "platform:com.example.b", plan.Create[0].GetIdentifier())
+	test.Eq(t, 0, len(plan.Update))
+}
+
+func TestApplyOnResult(t *testing.T) {
+	client := &fakeClient{failFor: map[string]bool{"platform:com.example.b": true}}
+	plan := &importer.Plan{
+		Create: []*apipb.Rule{
+			rule("platform:com.example.a", syncpb.Policy_ALLOWLIST, ""),
+			rule("platform:com.example.b", syncpb.Policy_ALLOWLIST, ""),
+		},
+	}
+
+	var mu sync.Mutex
+	seen := map[string]bool{}
+	importer.Apply(context.Background(), client, plan, importer.Options{
+		Concurrency: 2,
+		OnResult: func(r importer.RuleResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			seen[r.Rule.GetIdentifier()] = r.Err == nil
+		},
+	})
+
+	test.Eq(t, map[string]bool{
+		"platform:com.example.a": true,
+		"platform:com.example.b": false,
+	}, seen)
+}
+
+func TestImporterImport(t *testing.T) {
+	source := []*apipb.Rule{
+		rule("platform:com.apple.osascript", syncpb.Policy_BLOCKLIST, ""),
+		rule("platform:com.apple.osacompile", syncpb.Policy_BLOCKLIST, ""),
+	}
+	existing := []*apipb.Rule{
+		{RuleId: "r1", RuleType: syncpb.RuleType_SIGNINGID, Policy: syncpb.Policy_BLOCKLIST, Identifier: "platform:com.apple.osascript"},
+	}
+
+	// Without Sync every rule is created.
+	client := &fakeClient{existing: existing}
+	plan, result, err := (&importer.Importer{Client: client}).Import(context.Background(), source)
+	must.NoError(t, err)
+	test.Eq(t, 2, len(plan.Create))
+	test.Eq(t, 2, result.Created)
+
+	// With Sync only the missing rule is created.
+	client = &fakeClient{existing: existing}
+	var planned *importer.Plan
+	imp := &importer.Importer{
+		Client: client,
+		Sync:   true,
+		OnPlan: func(p *importer.Plan) { planned = p },
+	}
+	plan, result, err = imp.Import(context.Background(), source)
+	must.NoError(t, err)
+	test.Eq(t, plan, planned)
+	test.Eq(t, 1, len(plan.Create))
+	test.Eq(t, 1, len(plan.Unchanged))
+	test.Eq(t, 1, result.Created)
+	test.Eq(t, 1, len(client.created))
+
+	// A dry run plans against the existing rules without applying the plan.
+	client = &fakeClient{existing: existing}
+	plan, result, err = (&importer.Importer{Client: client, DryRun: true}).Import(context.Background(), source)
+	must.NoError(t, err)
+	test.Eq(t, 1, len(plan.Create))
+	test.Eq(t, 0, result.Created)
+	test.Eq(t, 0, len(client.created))
+}
+
+// committingSource is a source that records whether its progress was
+// committed.
+type committingSource struct {
+	rules      []*apipb.Rule
+	recordErrs rulehelpers.RecordErrors
+	committed  bool
+}
+
+func (s *committingSource) Rules(context.Context) ([]*apipb.Rule, error) {
+	return s.rules, s.recordErrs.Err()
+}
+
+func (s *committingSource) Commit() error {
+	s.committed = true
+	return nil
+}
+
+func TestImporterImportSource(t *testing.T) {
+	src := &committingSource{
+		rules: []*apipb.Rule{
+			rule("platform:com.apple.osascript", syncpb.Policy_BLOCKLIST, ""),
+			rule("platform:com.apple.osacompile", syncpb.Policy_ALLOWLIST, ""),
+			rule("not a signing id", syncpb.Policy_BLOCKLIST, ""),
+		},
+		recordErrs: rulehelpers.RecordErrors{
+			{Location: "rules.csv:5", Identifier: "platform:com.apple.Terminal", Err: errors.New("unknown policy")},
+		},
+	}
+	filter, err := rulehelpers.ParseFilter("", "BLOCKLIST")
+	must.NoError(t, err)
+
+	// Invalid records, including malformed identifiers, abort the import.
+	client := &fakeClient{}
+	var logged []string
+	imp := &importer.Importer{
+		Client: client,
+		Filter: filter,
+		Logf:   func(format string, args ...any) { logged = append(logged, fmt.Sprintf(format, args...)) },
+	}
+	_, _, err = imp.ImportSource(context.Background(), src)
+	var invalidErr *importer.InvalidRecordsError
+	must.ErrorAs(t, err, &invalidErr)
+	test.Eq(t, 2, len(invalidErr.Records))
+	test.Eq(t, 2, len(logged))
+	test.Eq(t, 0, len(client.created))
+	test.False(t, src.committed)
+
+	// With SkipInvalid the rules selected by the filter are imported, and the
+	// source's progress is recorded.
+	imp.SkipInvalid = true
+	plan, result, err := imp.ImportSource(context.Background(), src)
+	must.NoError(t, err)
+	test.Eq(t, 2, len(plan.Invalid))
+	test.Eq(t, 1, result.Created)
+	test.Eq(t, "platform:com.apple.osascript", client.created[0].GetIdentifier())
+	test.True(t, src.committed)
+}
+
+func TestImporterImportSourceResume(t *testing.T) {
+	src := &committingSource{
+		rules: []*apipb.Rule{
+			rule("platform:com.apple.osascript", syncpb.Policy_BLOCKLIST, ""),
+			rule("platform:com.apple.osacompile", syncpb.Policy_BLOCKLIST, ""),
+		},
+	}
+	path := filepath.Join(t.TempDir(), "import.journal")
+
+	// The failed rule isn't recorded as applied, and the source's progress
+	// isn't committed.
+	client := &fakeClient{failFor: map[string]bool{"platform:com.apple.osacompile": true}}
+	imp := &importer.Importer{Client: client, Journal: path}
+	_, result, err := imp.ImportSource(context.Background(), src)
+	must.NoError(t, err)
+	test.Eq(t, 1, result.Failed)
+	test.False(t, src.committed)
+
+	client = &fakeClient{}
+	imp = &importer.Importer{Client: client, Journal: path, Resume: true}
+	_, result, err = imp.ImportSource(context.Background(), src)
+	must.NoError(t, err)
+	test.Eq(t, 1, result.Skipped)
+	test.Eq(t, 1, result.Created)
+	test.Eq(t, "platform:com.apple.osacompile", client.created[0].GetIdentifier())
+	test.True(t, src.committed)
+
+	// Resuming with a changed source is refused unless allowed.
+	src.rules = src.rules[:1]
+	_, _, err = imp.ImportSource(context.Background(), src)
+	test.ErrorIs(t, err, importer.ErrSourceChanged)
+
+	imp.ResumeChangedSource = true
+	_, result, err = imp.ImportSource(context.Background(), src)
+	must.NoError(t, err)
+	test.Eq(t, 1, result.Skipped)
+	test.Eq(t, 0, result.Created)
+}
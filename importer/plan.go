@@ -0,0 +1,118 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// planRule is the JSON representation of a rule in a plan.
+type planRule struct {
+	RuleType   string `json:"rule_type"`
+	Policy     string `json:"policy"`
+	Identifier string `json:"identifier"`
+	CustomMsg  string `json:"custom_msg,omitempty"`
+	CustomURL  string `json:"custom_url,omitempty"`
+	Comment    string `json:"comment,omitempty"`
+}
+
+type planUpdate struct {
+	Rule     planRule `json:"rule"`
+	Existing planRule `json:"existing"`
+	Changes  []string `json:"changes"`
+}
+
+type planInvalid struct {
+	Location   string `json:"location"`
+	Identifier string `json:"identifier"`
+	Reason     string `json:"reason"`
+}
+
+type planJSON struct {
+	Create    []planRule    `json:"create"`
+	Update    []planUpdate  `json:"update"`
+	Unchanged []planRule    `json:"unchanged"`
+	Invalid   []planInvalid `json:"invalid"`
+}
+
+func toPlanRule(rule *apipb.Rule) planRule {
+	return planRule{
+		RuleType:   rule.GetRuleType().String(),
+		Policy:     rule.GetPolicy().String(),
+		Identifier: rule.GetIdentifier(),
+		CustomMsg:  rule.GetCustomMsg(),
+		CustomURL:  rule.GetCustomUrl(),
+		Comment:    rule.GetComment(),
+	}
+}
+
+// WriteJSON writes the plan to w as an indented JSON document.
+func (p *Plan) WriteJSON(w io.Writer) error {
+	out := planJSON{
+		Create:    []planRule{},
+		Update:    []planUpdate{},
+		Unchanged: []planRule{},
+		Invalid:   []planInvalid{},
+	}
+
+	for _, rule := range p.Create {
+		out.Create = append(out.Create, toPlanRule(rule))
+	}
+	for _, update := range p.Update {
+		out.Update = append(out.Update, planUpdate{
+			Rule:     toPlanRule(update.Rule),
+			Existing: toPlanRule(update.Existing),
+			Changes:  update.Changes(),
+		})
+	}
+	for _, rule := range p.Unchanged {
+		out.Unchanged = append(out.Unchanged, toPlanRule(rule))
+	}
+	for _, invalid := range p.Invalid {
+		out.Invalid = append(out.Invalid, planInvalid(invalid))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// WriteText writes a human-readable summary of the plan to w. Unchanged rules
+// are only counted since there are usually far more of them than changes.
+func (p *Plan) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Plan: %d to create, %d to update, %d unchanged, %d invalid\n",
+		len(p.Create), len(p.Update), len(p.Unchanged), len(p.Invalid))
+
+	if len(p.Create) > 0 {
+		fmt.Fprintf(&b, "\nTo create:\n")
+		for _, rule := range p.Create {
+			fmt.Fprintf(&b, "  + %s\n", describe(rule))
+		}
+	}
+
+	if len(p.Update) > 0 {
+		fmt.Fprintf(&b, "\nTo update:\n")
+		for _, update := range p.Update {
+			fmt.Fprintf(&b, "  ~ %s (%s)\n", describe(update.Rule), strings.Join(update.Changes(), ", "))
+		}
+	}
+
+	if len(p.Invalid) > 0 {
+		fmt.Fprintf(&b, "\nInvalid:\n")
+		for _, invalid := range p.Invalid {
+			fmt.Fprintf(&b, "  ! %s: %s: %s\n", invalid.Location, invalid.Identifier, invalid.Reason)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func describe(rule *apipb.Rule) string {
+	return fmt.Sprintf("%s %s %s", rule.GetRuleType(), rule.GetPolicy(), rule.GetIdentifier())
+}
@@ -0,0 +1,84 @@
+package importer_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/shoenig/test"
+	"github.com/shoenig/test/must"
+
+	"github.com/northpolesec/santa-rule-importer/importer"
+
+	syncpb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/sync"
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+func testPlan() *importer.Plan {
+	return &importer.Plan{
+		Create: []*apipb.Rule{
+			rule("platform:com.apple.osacompile", syncpb.Policy_BLOCKLIST, ""),
+		},
+		Update: []importer.Update{{
+			Existing: rule("EQHXZ8M8AV:com.google.Chrome", syncpb.Policy_BLOCKLIST, ""),
+			Rule:     rule("EQHXZ8M8AV:com.google.Chrome", syncpb.Policy_ALLOWLIST, "Chrome is allowed"),
+		}},
+		Unchanged: []*apipb.Rule{
+			rule("platform:com.apple.osascript", syncpb.Policy_BLOCKLIST, ""),
+		},
+		Invalid: []importer.Invalid{{
+			Location:   "rules.csv:4",
+			Identifier: "platform:com.apple.osascript",
+			Reason:     `unknown policy type: "DENY"`,
+		}},
+	}
+}
+
+func TestPlanWriteText(t *testing.T) {
+	var buf bytes.Buffer
+	must.NoError(t, testPlan().WriteText(&buf))
+
+	expected := `Plan: 1 to create, 1 to update, 1 unchanged, 1 invalid
+
+To create:
+  + SIGNINGID BLOCKLIST platform:com.apple.osacompile
+
+To update:
+  ~ SIGNINGID ALLOWLIST EQHXZ8M8AV:com.google.Chrome (policy, custom_msg)
+
+Invalid:
+  ! rules.csv:4: platform:com.apple.osascript: unknown policy type: "DENY"
+`
+	test.Eq(t, expected, buf.String())
+}
+
+func TestPlanWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	must.NoError(t, testPlan().WriteJSON(&buf))
+
+	var out struct {
+		Create []struct {
+			Identifier string `json:"identifier"`
+			Policy     string `json:"policy"`
+		} `json:"create"`
+		Update []struct {
+			Changes []string `json:"changes"`
+		} `json:"update"`
+		Unchanged []json.RawMessage `json:"unchanged"`
+		Invalid   []struct {
+			Location string `json:"location"`
+			Reason   string `json:"reason"`
+		} `json:"invalid"`
+	}
+	must.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+
+	must.Eq(t, 1, len(out.Create))
+	test.Eq(t, "platform:com.apple.osacompile", out.Create[0].Identifier)
+	test.Eq(t, "BLOCKLIST", out.Create[0].Policy)
+	must.Eq(t, 1, len(out.Update))
+	test.Eq(t, []string{"policy", "custom_msg"}, out.Update[0].Changes)
+	test.Eq(t, 1, len(out.Unchanged))
+	must.Eq(t, 1, len(out.Invalid))
+	test.Eq(t, "rules.csv:4", out.Invalid[0].Location)
+	test.Eq(t, `unknown policy type: "DENY"`, out.Invalid[0].Reason)
+}
@@ -0,0 +1,4 @@
+// Package journal records the outcome of every rule applied during an import so
+// that an interrupted import can be resumed without sending the same rules
+// again.
+package journal
@@ -0,0 +1,203 @@
+package journal
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// Fingerprint returns a stable hash of the contents of a rule. Any change to
+// the rule type, policy, identifier, custom message, custom URL, comment or tag
+// produces a different fingerprint.
+func Fingerprint(rule *apipb.Rule) string {
+	h := sha256.New()
+	for _, field := range []string{
+		rule.GetRuleType().String(),
+		rule.GetPolicy().String(),
+		rule.GetIdentifier(),
+		rule.GetCustomMsg(),
+		rule.GetCustomUrl(),
+		rule.GetComment(),
+		rule.GetTag(),
+	} {
+		// Length-prefix each field so that adjacent fields can't be
+		// confused with each other.
+		fmt.Fprintf(h, "%d:%s", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// SourceFingerprint returns a hash of every rule in a source, in order.
+func SourceFingerprint(rules []*apipb.Rule) string {
+	h := sha256.New()
+	for _, rule := range rules {
+		io.WriteString(h, Fingerprint(rule))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Entry is a single line in the journal. The first line of a journal records
+// the fingerprint of the source being imported; every following line records
+// the outcome of one rule.
+type Entry struct {
+	Time        time.Time `json:"time"`
+	Source      string    `json:"source,omitempty"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	Identifier  string    `json:"identifier,omitempty"`
+	Action      string    `json:"action,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// Journal is an append-only record of rule outcomes. It is safe for concurrent
+// use.
+type Journal struct {
+	mu      sync.Mutex
+	f       *os.File
+	enc     *json.Encoder
+	applied map[string]bool
+	partial bool
+
+	// SourceChanged is set when resuming a journal that was written for a
+	// different source. Only rules whose contents are unchanged are skipped.
+	SourceChanged bool
+}
+
+// Open opens the journal at path for an import of rules. If resume is true the
+// outcomes already in the journal are loaded and new outcomes are appended;
+// otherwise any existing journal is replaced. A file at path that isn't a
+// journal is never replaced.
+func Open(path string, rules []*apipb.Rule, resume bool) (*Journal, error) {
+	j := &Journal{applied: map[string]bool{}}
+	source := SourceFingerprint(rules)
+
+	previous, err := j.load(path)
+	if err != nil {
+		return nil, err
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume && previous != "" {
+		flags = os.O_WRONLY | os.O_APPEND
+		j.SourceChanged = previous != source
+	} else {
+		j.applied, j.partial = map[string]bool{}, false
+	}
+
+	f, err := os.OpenFile(path, flags, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	j.f = f
+	j.enc = json.NewEncoder(f)
+
+	if flags&os.O_APPEND != 0 && j.partial {
+		// Terminate the incomplete line so new entries start on their own.
+		if _, err := f.WriteString("\n"); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to write journal: %w", err)
+		}
+	}
+
+	if flags&os.O_APPEND == 0 {
+		if err := j.enc.Encode(Entry{Time: time.Now(), Source: source}); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to write journal: %w", err)
+		}
+	}
+
+	return j, nil
+}
+
+// load reads the entries in an existing journal and returns the source
+// fingerprint it was written for, or an empty string if there is no journal.
+// A journal whose first line doesn't record a source is an error.
+func (j *Journal) load(path string) (string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer f.Close()
+
+	var source string
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		var entry Entry
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if line == 1 {
+			// Without the source entry there is no telling what the
+			// journal was written for, and it must not be replaced.
+			if err != nil || entry.Source == "" {
+				return "", fmt.Errorf("%s is not a journal: its first line doesn't record a source", path)
+			}
+			source = entry.Source
+			continue
+		}
+		if err != nil {
+			// The last line may be incomplete if the import was killed
+			// while writing it.
+			continue
+		}
+		if entry.Error == "" {
+			j.applied[entry.Fingerprint] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	// Check whether the journal ends part way through a line.
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil {
+			j.partial = last[0] != '\n'
+		}
+	}
+
+	return source, nil
+}
+
+// Applied reports whether the journal records rule as successfully applied.
+func (j *Journal) Applied(rule *apipb.Rule) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.applied[Fingerprint(rule)]
+}
+
+// Record appends the outcome of applying rule to the journal.
+func (j *Journal) Record(rule *apipb.Rule, action string, ruleErr error) error {
+	entry := Entry{
+		Time:        time.Now(),
+		Fingerprint: Fingerprint(rule),
+		Identifier:  rule.GetIdentifier(),
+		Action:      action,
+	}
+	if ruleErr != nil {
+		entry.Error = ruleErr.Error()
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.enc.Encode(entry); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if ruleErr == nil {
+		j.applied[entry.Fingerprint] = true
+	}
+	return nil
+}
+
+// Close closes the journal file.
+func (j *Journal) Close() error {
+	return j.f.Close()
+}
@@ -0,0 +1,151 @@
+package journal_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shoenig/test"
+	"github.com/shoenig/test/must"
+
+	"github.com/northpolesec/santa-rule-importer/internal/journal"
+
+	syncpb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/sync"
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+func testRules() []*apipb.Rule {
+	return []*apipb.Rule{
+		{RuleType: syncpb.RuleType_SIGNINGID, Policy: syncpb.Policy_BLOCKLIST, Identifier: "platform:com.apple.osascript"},
+		{RuleType: syncpb.RuleType_SIGNINGID, Policy: syncpb.Policy_BLOCKLIST, Identifier: "platform:com.apple.osacompile"},
+		{RuleType: syncpb.RuleType_TEAMID, Policy: syncpb.Policy_ALLOWLIST, Identifier: "EQHXZ8M8AV"},
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	a := &apipb.Rule{RuleType: syncpb.RuleType_TEAMID, Policy: syncpb.Policy_ALLOWLIST, Identifier: "EQHXZ8M8AV"}
+	b := &apipb.Rule{RuleType: syncpb.RuleType_TEAMID, Policy: syncpb.Policy_ALLOWLIST, Identifier: "EQHXZ8M8AV"}
+	test.Eq(t, journal.Fingerprint(a), journal.Fingerprint(b))
+
+	b.Comment = "changed"
+	test.NotEq(t, journal.Fingerprint(a), journal.Fingerprint(b))
+
+	// Moving text between fields must change the fingerprint
+	c := &apipb.Rule{CustomMsg: "ab", CustomUrl: "c"}
+	d := &apipb.Rule{CustomMsg: "a", CustomUrl: "bc"}
+	test.NotEq(t, journal.Fingerprint(c), journal.Fingerprint(d))
+}
+
+func TestResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "import.journal")
+	rules := testRules()
+
+	j, err := journal.Open(path, rules, false)
+	must.NoError(t, err)
+	must.NoError(t, j.Record(rules[0], "create", nil))
+	must.NoError(t, j.Record(rules[1], "create", errors.New("unavailable")))
+	must.NoError(t, j.Close())
+
+	j, err = journal.Open(path, rules, true)
+	must.NoError(t, err)
+	test.False(t, j.SourceChanged)
+	test.True(t, j.Applied(rules[0]))
+	test.False(t, j.Applied(rules[1]))
+	test.False(t, j.Applied(rules[2]))
+
+	// Outcomes recorded after resuming are appended to the journal
+	must.NoError(t, j.Record(rules[1], "create", nil))
+	must.NoError(t, j.Close())
+
+	j, err = journal.Open(path, rules, true)
+	must.NoError(t, err)
+	test.True(t, j.Applied(rules[0]))
+	test.True(t, j.Applied(rules[1]))
+	must.NoError(t, j.Close())
+}
+
+func TestResumeChangedSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "import.journal")
+	rules := testRules()
+
+	j, err := journal.Open(path, rules, false)
+	must.NoError(t, err)
+	must.NoError(t, j.Record(rules[0], "create", nil))
+	must.NoError(t, j.Record(rules[1], "create", nil))
+	must.NoError(t, j.Close())
+
+	changed := testRules()
+	changed[1].Policy = syncpb.Policy_ALLOWLIST
+
+	j, err = journal.Open(path, changed, true)
+	must.NoError(t, err)
+	defer j.Close()
+
+	test.True(t, j.SourceChanged)
+	test.True(t, j.Applied(changed[0]))
+	test.False(t, j.Applied(changed[1]))
+}
+
+func TestOpenWithoutResumeReplacesJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "import.journal")
+	rules := testRules()
+
+	j, err := journal.Open(path, rules, false)
+	must.NoError(t, err)
+	must.NoError(t, j.Record(rules[0], "create", nil))
+	must.NoError(t, j.Close())
+
+	j, err = journal.Open(path, rules, false)
+	must.NoError(t, err)
+	test.False(t, j.Applied(rules[0]))
+	must.NoError(t, j.Close())
+
+	j, err = journal.Open(path, rules, true)
+	must.NoError(t, err)
+	test.False(t, j.Applied(rules[0]))
+	must.NoError(t, j.Close())
+}
+
+func TestResumeTruncatedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "import.journal")
+	rules := testRules()
+
+	j, err := journal.Open(path, rules, false)
+	must.NoError(t, err)
+	must.NoError(t, j.Record(rules[0], "create", nil))
+	must.NoError(t, j.Close())
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	must.NoError(t, err)
+	_, err = f.WriteString(`{"time":"2025-01-01T00:00:00Z","finger`)
+	must.NoError(t, err)
+	must.NoError(t, f.Close())
+
+	j, err = journal.Open(path, rules, true)
+	must.NoError(t, err)
+	test.True(t, j.Applied(rules[0]))
+	must.NoError(t, j.Record(rules[1], "create", nil))
+	must.NoError(t, j.Close())
+
+	// The entry written after the truncated line is still readable
+	j, err = journal.Open(path, rules, true)
+	must.NoError(t, err)
+	defer j.Close()
+	test.True(t, j.Applied(rules[1]))
+}
+
+func TestResumeInvalidJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "import.journal")
+	must.NoError(t, os.WriteFile(path, []byte("not a journal\n"), 0o600))
+
+	for _, resume := range []bool{true, false} {
+		_, err := journal.Open(path, testRules(), resume)
+		test.ErrorContains(t, err, "is not a journal")
+
+		// The file is left untouched.
+		data, err := os.ReadFile(path)
+		must.NoError(t, err)
+		test.Eq(t, "not a journal\n", string(data))
+	}
+}
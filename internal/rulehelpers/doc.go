@@ -1,3 +0,0 @@
-// Package rulehelpers provides helper functions for importing rules from moroz
-// and rudolph.
-package rulehelpers
@@ -0,0 +1,3 @@
+// Package textutil holds the small reading and writing helpers shared by the
+// source packages.
+package textutil
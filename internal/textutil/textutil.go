@@ -0,0 +1,58 @@
+package textutil
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// Stderr is the path that WriteFile writes to standard error. It is the same
+// as source.Stdin, so that "-" means the terminal for reports too.
+const Stderr = "-"
+
+// FirstByte returns the first byte of br that isn't white space, without
+// consuming it.
+func FirstByte(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return b, br.UnreadByte()
+		}
+	}
+}
+
+// SplitList splits a comma separated list, trimming white space and dropping
+// empty items.
+func SplitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// WriteFile creates the file at path and writes it with write, or writes to
+// standard error if path is Stderr.
+func WriteFile(path string, write func(w io.Writer) error) error {
+	if path == Stderr {
+		return write(os.Stderr)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,55 @@
+package textutil_test
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shoenig/test"
+	"github.com/shoenig/test/must"
+
+	"github.com/northpolesec/santa-rule-importer/internal/textutil"
+)
+
+func TestFirstByte(t *testing.T) {
+	br := bufio.NewReader(strings.NewReader(" \r\n\t[1]"))
+	b, err := textutil.FirstByte(br)
+	must.NoError(t, err)
+	test.Eq(t, '[', b)
+
+	// The byte is left to be read.
+	rest, err := io.ReadAll(br)
+	must.NoError(t, err)
+	test.Eq(t, "[1]", string(rest))
+
+	_, err = textutil.FirstByte(bufio.NewReader(strings.NewReader(" \n")))
+	test.ErrorIs(t, err, io.EOF)
+}
+
+func TestSplitList(t *testing.T) {
+	test.Eq(t, []string{"BINARY", "TEAMID"}, textutil.SplitList(" BINARY, ,TEAMID,"))
+	test.Nil(t, textutil.SplitList(""))
+}
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.txt")
+	must.NoError(t, textutil.WriteFile(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, "report\n")
+		return err
+	}))
+	data, err := os.ReadFile(path)
+	must.NoError(t, err)
+	test.Eq(t, "report\n", string(data))
+
+	var buf bytes.Buffer
+	err = textutil.WriteFile(filepath.Join(t.TempDir(), "missing", "report.txt"), func(w io.Writer) error {
+		_, err := buf.WriteString("unused")
+		return err
+	})
+	test.Error(t, err)
+	test.Eq(t, 0, buf.Len())
+}
@@ -1,3 +0,0 @@
-// Package zentral provides functionality to retrieve Santa rules from Zentral
-// and convert them to Workshop format.
-package zentral
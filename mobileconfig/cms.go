@@ -0,0 +1,155 @@
+package mobileconfig
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+// oidSignedData identifies CMS SignedData content.
+var oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+// IsSigned reports whether data looks like a CMS SignedData structure, the
+// format of signed configuration profiles.
+func IsSigned(data []byte) bool {
+	oid, _ := asn1.Marshal(oidSignedData)
+	// The OID follows the outer SEQUENCE header, which is at most 6 bytes.
+	return len(data) > 0 && data[0] == 0x30 && bytes.Contains(data[:min(len(data), 6+len(oid))], oid)
+}
+
+// Unwrap returns the content of a signed configuration profile. The signature
+// is not verified. Profiles signed by macOS use BER indefinite lengths, which
+// encoding/asn1 can't parse, so the structure is walked with a minimal BER
+// reader.
+func Unwrap(data []byte) ([]byte, error) {
+	// ContentInfo ::= SEQUENCE { contentType OID, content [0] EXPLICIT ANY }
+	contentInfo, _, err := parseBER(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := expectOID(contentInfo.child(0), oidSignedData); err != nil {
+		return nil, err
+	}
+
+	// SignedData ::= SEQUENCE { version, digestAlgorithms,
+	//   encapContentInfo, ... }
+	// EncapsulatedContentInfo ::= SEQUENCE { eContentType OID,
+	//   eContent [0] EXPLICIT OCTET STRING OPTIONAL }
+	content := contentInfo.child(1).child(0).child(2).child(1).child(0)
+	if content == nil || content.class != asn1.ClassUniversal || content.tag != asn1.TagOctetString {
+		return nil, errors.New("signed profile has no content")
+	}
+	return content.bytes(), nil
+}
+
+// berValue is a decoded BER element.
+type berValue struct {
+	class       int
+	tag         int
+	constructed bool
+	content     []byte
+	children    []*berValue
+}
+
+// child returns the i'th element of a constructed value, or nil.
+func (v *berValue) child(i int) *berValue {
+	if v == nil || i >= len(v.children) {
+		return nil
+	}
+	return v.children[i]
+}
+
+// bytes returns the content of a string value, joining the segments of a
+// constructed string.
+func (v *berValue) bytes() []byte {
+	if !v.constructed {
+		return v.content
+	}
+	var b []byte
+	for _, c := range v.children {
+		b = append(b, c.bytes()...)
+	}
+	return b
+}
+
+func expectOID(v *berValue, want asn1.ObjectIdentifier) error {
+	if v == nil || v.tag != asn1.TagOID || v.constructed {
+		return errors.New("not a CMS structure")
+	}
+	var oid asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(append([]byte{asn1.TagOID, byte(len(v.content))}, v.content...), &oid); err != nil {
+		return err
+	}
+	if !oid.Equal(want) {
+		return fmt.Errorf("unsupported CMS content type %v", oid)
+	}
+	return nil
+}
+
+// parseBER decodes the element at the start of data and returns the bytes
+// that follow it.
+func parseBER(data []byte) (*berValue, []byte, error) {
+	if len(data) < 2 {
+		return nil, nil, errors.New("truncated BER element")
+	}
+
+	v := &berValue{
+		class:       int(data[0] >> 6),
+		constructed: data[0]&0x20 != 0,
+		tag:         int(data[0] & 0x1f),
+	}
+	data = data[1:]
+	if v.tag == 0x1f {
+		// High tag numbers don't appear in the structures read here.
+		return nil, nil, errors.New("unsupported BER tag")
+	}
+
+	l := int(data[0])
+	data = data[1:]
+	switch {
+	case l == 0x80:
+		if !v.constructed {
+			return nil, nil, errors.New("indefinite length on primitive BER element")
+		}
+		// Children follow until an end-of-contents marker.
+		for {
+			if len(data) >= 2 && data[0] == 0 && data[1] == 0 {
+				return v, data[2:], nil
+			}
+			c, rest, err := parseBER(data)
+			if err != nil {
+				return nil, nil, err
+			}
+			v.children = append(v.children, c)
+			data = rest
+		}
+	case l > 0x80:
+		n := l & 0x7f
+		if n > 4 || len(data) < n {
+			return nil, nil, errors.New("invalid BER length")
+		}
+		l = 0
+		for _, b := range data[:n] {
+			l = l<<8 | int(b)
+		}
+		data = data[n:]
+	}
+
+	if l > len(data) {
+		return nil, nil, errors.New("truncated BER element")
+	}
+	v.content, data = data[:l], data[l:]
+
+	if v.constructed {
+		for rest := v.content; len(rest) > 0; {
+			c, r, err := parseBER(rest)
+			if err != nil {
+				return nil, nil, err
+			}
+			v.children = append(v.children, c)
+			rest = r
+		}
+	}
+	return v, data, nil
+}
@@ -0,0 +1,4 @@
+// Package mobileconfig reads the StaticRules of Santa configuration profiles
+// and plists, including signed profiles exported from an MDM, and writes rules
+// as a configuration profile for Macs that can't sync with Workshop.
+package mobileconfig
@@ -0,0 +1,294 @@
+package mobileconfig
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"howett.net/plist"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// PayloadTypes are the preference domains Santa reads its configuration from.
+var PayloadTypes = []string{"com.northpolesec.santa", "com.google.santa"}
+
+// managedPreferences is the payload type of custom settings profiles, which
+// wrap the preferences of other domains.
+const managedPreferences = "com.apple.ManagedClient.preferences"
+
+// Rule is an entry in the StaticRules array of a Santa configuration.
+type Rule struct {
+	Identifier string `plist:"identifier"`
+	Policy     string `plist:"policy"`
+	RuleType   string `plist:"rule_type"`
+	CustomMsg  string `plist:"custom_msg,omitempty"`
+	CustomURL  string `plist:"custom_url,omitempty"`
+	Comment    string `plist:"comment,omitempty"`
+}
+
+// ToWorkshopRule converts a static rule to Workshop format.
+func (rule Rule) ToWorkshopRule() (*apipb.Rule, error) {
+	r, err := rulehelpers.NewRule(rule.RuleType, rule.Policy, rule.Identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	r.CustomMsg = rule.CustomMsg
+	r.CustomUrl = rule.CustomURL
+	r.Comment = rule.Comment
+	return r, nil
+}
+
+// ParseRulesFromFile reads a configuration profile or plist and returns the
+// StaticRules of every Santa payload it contains. Rules with an unknown rule
+// type or policy are skipped and reported in a rulehelpers.RecordErrors error
+// alongside the remaining rules.
+func ParseRulesFromFile(filePath string) ([]*apipb.Rule, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseProfile(data, filePath)
+}
+
+// ParseRules is like ParseRulesFromFile but reads the profile from r. name
+// identifies the profile in record errors.
+func ParseRules(r io.Reader, name string) ([]*apipb.Rule, error) {
+	rules, _, err := parseRules(r, name)
+	return rules, err
+}
+
+func parseRules(r io.Reader, name string) ([]*apipb.Rule, rulehelpers.Locations, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parseProfile(data, name)
+}
+
+// ParseProfile reads the StaticRules from an XML or binary plist, unwrapping
+// it first if it is a signed profile. The plist may be a configuration profile
+// with Santa payloads, either directly or as custom settings, or a plain
+// preferences plist with a top-level StaticRules array.
+func ParseProfile(data []byte, name string) ([]*apipb.Rule, error) {
+	rules, _, err := parseProfile(data, name)
+	return rules, err
+}
+
+func parseProfile(data []byte, name string) ([]*apipb.Rule, rulehelpers.Locations, error) {
+	if IsSigned(data) {
+		var err error
+		if data, err = Unwrap(data); err != nil {
+			return nil, nil, fmt.Errorf("failed to unwrap signed profile: %w", err)
+		}
+	}
+
+	var root map[string]any
+	if _, err := plist.Unmarshal(data, &root); err != nil {
+		return nil, nil, err
+	}
+
+	rules := []*apipb.Rule{}
+	locs := rulehelpers.Locations{}
+	var recordErrs rulehelpers.RecordErrors
+
+	for _, set := range staticRules(root) {
+		for i, entry := range set.entries {
+			location := fmt.Sprintf("%s: %s[%d]", name, set.location, i)
+			rule := ruleFromDict(entry)
+			r, err := rule.ToWorkshopRule()
+			if err != nil {
+				recordErrs = append(recordErrs, &rulehelpers.RecordError{
+					Location:   location,
+					Identifier: rule.Identifier,
+					Err:        err,
+				})
+				continue
+			}
+			rules = append(rules, r)
+			locs[r] = location
+		}
+	}
+
+	return rules, locs, recordErrs.Err()
+}
+
+// ruleSet is a StaticRules array and the path of keys to it.
+type ruleSet struct {
+	location string
+	entries  []any
+}
+
+// staticRules finds the StaticRules arrays in a decoded plist.
+func staticRules(root map[string]any) []ruleSet {
+	var sets []ruleSet
+	if entries, ok := root["StaticRules"].([]any); ok {
+		sets = append(sets, ruleSet{"StaticRules", entries})
+	}
+
+	payloads, _ := root["PayloadContent"].([]any)
+	for i, p := range payloads {
+		payload, _ := p.(map[string]any)
+		payloadType, _ := payload["PayloadType"].(string)
+		location := fmt.Sprintf("PayloadContent[%d]", i)
+
+		if slices.Contains(PayloadTypes, payloadType) {
+			if entries, ok := payload["StaticRules"].([]any); ok {
+				sets = append(sets, ruleSet{location + ".StaticRules", entries})
+			}
+			continue
+		}
+
+		if payloadType != managedPreferences {
+			continue
+		}
+		// Custom settings are nested as
+		// PayloadContent.<domain>.Forced[].mcx_preference_settings.
+		domains, _ := payload["PayloadContent"].(map[string]any)
+		for _, domain := range PayloadTypes {
+			settings, _ := domains[domain].(map[string]any)
+			forced, _ := settings["Forced"].([]any)
+			for j, f := range forced {
+				mcx, _ := f.(map[string]any)
+				prefs, _ := mcx["mcx_preference_settings"].(map[string]any)
+				if entries, ok := prefs["StaticRules"].([]any); ok {
+					sets = append(sets, ruleSet{fmt.Sprintf("%s.PayloadContent.%s.Forced[%d].mcx_preference_settings.StaticRules", location, domain, j), entries})
+				}
+			}
+		}
+	}
+	return sets
+}
+
+// ruleFromDict reads a StaticRules entry. Values that aren't strings are
+// treated as missing. Older configurations name the identifier sha256.
+func ruleFromDict(entry any) Rule {
+	dict, _ := entry.(map[string]any)
+	str := func(key string) string {
+		s, _ := dict[key].(string)
+		return s
+	}
+
+	rule := Rule{
+		Identifier: str("identifier"),
+		Policy:     str("policy"),
+		RuleType:   str("rule_type"),
+		CustomMsg:  str("custom_msg"),
+		CustomURL:  str("custom_url"),
+		Comment:    str("comment"),
+	}
+	if rule.Identifier == "" {
+		rule.Identifier = str("sha256")
+	}
+	return rule
+}
+
+// FromWorkshopRule converts a Workshop rule to a static rule.
+func FromWorkshopRule(rule *apipb.Rule) Rule {
+	return Rule{
+		Identifier: rule.GetIdentifier(),
+		Policy:     rule.GetPolicy().String(),
+		RuleType:   rule.GetRuleType().String(),
+		CustomMsg:  rule.GetCustomMsg(),
+		CustomURL:  rule.GetCustomUrl(),
+		Comment:    rule.GetComment(),
+	}
+}
+
+// ProfileOptions control the payload keys of a generated profile.
+type ProfileOptions struct {
+	// Identifier is the PayloadIdentifier of the profile. The Santa payload
+	// is identified by Identifier followed by its payload type.
+	Identifier string
+
+	// Organization, if set, is the PayloadOrganization of the profile.
+	Organization string
+
+	// DisplayName is the PayloadDisplayName of the profile.
+	DisplayName string
+
+	// PayloadType is the preference domain of the Santa payload.
+	PayloadType string
+}
+
+// Default profile options.
+const (
+	DefaultIdentifier  = "com.northpolesec.santa.staticrules"
+	DefaultDisplayName = "Santa Static Rules"
+)
+
+type profile struct {
+	PayloadContent      []payload `plist:"PayloadContent"`
+	PayloadDisplayName  string    `plist:"PayloadDisplayName"`
+	PayloadIdentifier   string    `plist:"PayloadIdentifier"`
+	PayloadOrganization string    `plist:"PayloadOrganization,omitempty"`
+	PayloadScope        string    `plist:"PayloadScope"`
+	PayloadType         string    `plist:"PayloadType"`
+	PayloadUUID         string    `plist:"PayloadUUID"`
+	PayloadVersion      int       `plist:"PayloadVersion"`
+}
+
+type payload struct {
+	PayloadDisplayName string `plist:"PayloadDisplayName"`
+	PayloadIdentifier  string `plist:"PayloadIdentifier"`
+	PayloadType        string `plist:"PayloadType"`
+	PayloadUUID        string `plist:"PayloadUUID"`
+	PayloadVersion     int    `plist:"PayloadVersion"`
+	StaticRules        []Rule `plist:"StaticRules"`
+}
+
+// WriteProfile writes rules to w as an XML configuration profile with a single
+// Santa payload containing a StaticRules array. Tags have no equivalent in a
+// profile and are dropped.
+func WriteProfile(w io.Writer, rules []*apipb.Rule, opts ProfileOptions) error {
+	if opts.Identifier == "" {
+		opts.Identifier = DefaultIdentifier
+	}
+	if opts.DisplayName == "" {
+		opts.DisplayName = DefaultDisplayName
+	}
+	if opts.PayloadType == "" {
+		opts.PayloadType = PayloadTypes[0]
+	}
+
+	staticRules := make([]Rule, 0, len(rules))
+	for _, rule := range rules {
+		staticRules = append(staticRules, FromWorkshopRule(rule))
+	}
+
+	p := profile{
+		PayloadContent: []payload{{
+			PayloadDisplayName: opts.DisplayName,
+			PayloadIdentifier:  opts.Identifier + "." + opts.PayloadType,
+			PayloadType:        opts.PayloadType,
+			PayloadUUID:        newUUID(),
+			PayloadVersion:     1,
+			StaticRules:        staticRules,
+		}},
+		PayloadDisplayName:  opts.DisplayName,
+		PayloadIdentifier:   opts.Identifier,
+		PayloadOrganization: opts.Organization,
+		PayloadScope:        "System",
+		PayloadType:         "Configuration",
+		PayloadUUID:         newUUID(),
+		PayloadVersion:      1,
+	}
+
+	enc := plist.NewEncoderForFormat(w, plist.XMLFormat)
+	enc.Indent("\t")
+	return enc.Encode(p)
+}
+
+// newUUID returns a random (version 4) UUID in the upper case form used by
+// profiles.
+func newUUID() string {
+	var u [16]byte
+	rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%X-%X-%X-%X-%X", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
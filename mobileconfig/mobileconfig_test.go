@@ -0,0 +1,138 @@
+package mobileconfig_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/shoenig/test"
+	"github.com/shoenig/test/must"
+	"howett.net/plist"
+
+	"github.com/northpolesec/santa-rule-importer/mobileconfig"
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/northpolesec/santa-rule-importer/source"
+
+	syncpb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/sync"
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+func checkRules(t *testing.T, rules []*apipb.Rule) {
+	t.Helper()
+
+	must.Eq(t, 2, len(rules))
+	test.Eq(t, "EQHXZ8M8AV", rules[0].GetIdentifier())
+	test.Eq(t, syncpb.RuleType_TEAMID, rules[0].GetRuleType())
+	test.Eq(t, syncpb.Policy_ALLOWLIST, rules[0].GetPolicy())
+	test.Eq(t, "Google is allowed", rules[0].GetCustomMsg())
+
+	// Read from the custom settings payload, using the legacy sha256 key.
+	test.Eq(t, "d84db96af8c2e60ac4c851a21ec460f6f84e0235beb17d24a78712b9b021ed57", rules[1].GetIdentifier())
+	test.Eq(t, syncpb.RuleType_BINARY, rules[1].GetRuleType())
+	test.Eq(t, syncpb.Policy_BLOCKLIST, rules[1].GetPolicy())
+	test.Eq(t, "https://example.com/blocked", rules[1].GetCustomUrl())
+}
+
+func TestParseRulesFromFile(t *testing.T) {
+	rules, err := mobileconfig.ParseRulesFromFile("testdata/santa.mobileconfig")
+
+	var recordErrs rulehelpers.RecordErrors
+	must.ErrorAs(t, err, &recordErrs)
+	must.Eq(t, 1, len(recordErrs))
+	test.Eq(t, "testdata/santa.mobileconfig: PayloadContent[0].StaticRules[1]", recordErrs[0].Location)
+	test.Eq(t, "platform:com.apple.osascript", recordErrs[0].Identifier)
+	test.ErrorContains(t, recordErrs[0], "unknown policy type")
+
+	checkRules(t, rules)
+}
+
+func TestParseSignedProfile(t *testing.T) {
+	data, err := os.ReadFile("testdata/signed.mobileconfig")
+	must.NoError(t, err)
+	must.True(t, mobileconfig.IsSigned(data))
+
+	rules, err := mobileconfig.ParseProfile(data, "signed.mobileconfig")
+	var recordErrs rulehelpers.RecordErrors
+	must.ErrorAs(t, err, &recordErrs)
+	checkRules(t, rules)
+}
+
+func TestParseBinaryPlist(t *testing.T) {
+	// A plain preferences plist, as written by defaults.
+	data, err := plist.Marshal(map[string]any{
+		"ClientMode": 1,
+		"StaticRules": []mobileconfig.Rule{
+			{Identifier: "EQHXZ8M8AV", Policy: "BLOCKLIST", RuleType: "TEAMID", Comment: "Google"},
+		},
+	}, plist.BinaryFormat)
+	must.NoError(t, err)
+
+	reg, ok := source.Detect(data)
+	must.True(t, ok)
+	test.Eq(t, "mobileconfig", reg.Name)
+
+	rules, err := mobileconfig.ParseRules(bytes.NewReader(data), "stdin")
+	must.NoError(t, err)
+	must.Eq(t, 1, len(rules))
+	test.Eq(t, syncpb.Policy_BLOCKLIST, rules[0].GetPolicy())
+	test.Eq(t, "Google", rules[0].GetComment())
+}
+
+func TestUnwrapInvalid(t *testing.T) {
+	_, err := mobileconfig.Unwrap([]byte{0x30, 0x80, 0x06})
+	test.Error(t, err)
+
+	_, err = mobileconfig.ParseProfile([]byte("not a plist"), "bad")
+	test.Error(t, err)
+}
+
+func TestWriteProfileRoundTrip(t *testing.T) {
+	rules := []*apipb.Rule{
+		{
+			RuleType:   syncpb.RuleType_TEAMID,
+			Policy:     syncpb.Policy_BLOCKLIST,
+			Identifier: "BJ4HAAB9B3",
+			CustomMsg:  "Zoom is blocked",
+			CustomUrl:  "https://example.com/zoom",
+			Comment:    "Zoom",
+		},
+		{
+			RuleType:   syncpb.RuleType_SIGNINGID,
+			Policy:     syncpb.Policy_ALLOWLIST,
+			Identifier: "EQHXZ8M8AV:com.google.Chrome",
+		},
+	}
+
+	var buf bytes.Buffer
+	must.NoError(t, mobileconfig.WriteProfile(&buf, rules, mobileconfig.ProfileOptions{
+		Identifier:   "com.example.santa",
+		Organization: "Example Corp",
+	}))
+
+	var profile map[string]any
+	_, err := plist.Unmarshal(buf.Bytes(), &profile)
+	must.NoError(t, err)
+	test.Eq(t, "com.example.santa", profile["PayloadIdentifier"])
+	test.Eq(t, "Example Corp", profile["PayloadOrganization"])
+	test.Eq(t, mobileconfig.DefaultDisplayName, profile["PayloadDisplayName"])
+	test.Eq(t, "Configuration", profile["PayloadType"])
+
+	payloads := profile["PayloadContent"].([]any)
+	must.Eq(t, 1, len(payloads))
+	payload := payloads[0].(map[string]any)
+	test.Eq(t, "com.northpolesec.santa", payload["PayloadType"])
+	test.Eq(t, "com.example.santa.com.northpolesec.santa", payload["PayloadIdentifier"])
+	test.NotEq(t, profile["PayloadUUID"], payload["PayloadUUID"])
+
+	parsed, err := mobileconfig.ParseProfile(buf.Bytes(), "profile")
+	must.NoError(t, err)
+	must.Eq(t, len(rules), len(parsed))
+	for i := range rules {
+		test.Eq(t, rules[i].GetIdentifier(), parsed[i].GetIdentifier())
+		test.Eq(t, rules[i].GetRuleType(), parsed[i].GetRuleType())
+		test.Eq(t, rules[i].GetPolicy(), parsed[i].GetPolicy())
+		test.Eq(t, rules[i].GetCustomMsg(), parsed[i].GetCustomMsg())
+		test.Eq(t, rules[i].GetCustomUrl(), parsed[i].GetCustomUrl())
+		test.Eq(t, rules[i].GetComment(), parsed[i].GetComment())
+	}
+}
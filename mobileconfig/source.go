@@ -0,0 +1,44 @@
+package mobileconfig
+
+import (
+	"bytes"
+	"flag"
+	"io"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/northpolesec/santa-rule-importer/source"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+func init() {
+	source.Register(source.Registration{
+		Name:        "mobileconfig",
+		Description: "Santa configuration profile or plist with StaticRules",
+		Extensions:  []string{".mobileconfig", ".plist"},
+		Detect:      detect,
+		Driver:      driver{},
+	})
+}
+
+type driver struct{}
+
+func (driver) SetFlags(*flag.FlagSet) {}
+
+func (d driver) Open(path string) (source.Source, error) {
+	return source.OpenFile(d, path), nil
+}
+
+func (driver) Parse(r io.Reader, name string) ([]*apipb.Rule, rulehelpers.Locations, error) {
+	return parseRules(r, name)
+}
+
+// detect reports whether head is a binary plist, an XML plist or a signed
+// profile.
+func detect(head []byte) bool {
+	if bytes.HasPrefix(head, []byte("bplist00")) || IsSigned(head) {
+		return true
+	}
+	head = bytes.TrimSpace(head)
+	return bytes.HasPrefix(head, []byte("<?xml")) && bytes.Contains(head, []byte("<plist"))
+}
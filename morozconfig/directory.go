@@ -0,0 +1,256 @@
+package morozconfig
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"google.golang.org/protobuf/proto"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// GlobalConfig is the name of the configuration Moroz serves to machines
+// without their own configuration file.
+const GlobalConfig = "global.toml"
+
+// Scope selects which rules of a configs directory are imported.
+type Scope string
+
+const (
+	// ScopeGlobal imports only the rules in the global configuration.
+	ScopeGlobal Scope = "global"
+	// ScopeUnion imports the global rules and every machine's rules as
+	// global rules. Where a machine's rule has the same rule type and
+	// identifier as a global rule, the global rule is kept.
+	ScopeUnion Scope = "union"
+	// ScopeMachine imports the global rules as global rules and each
+	// machine's other rules scoped to the machine with a host tag.
+	ScopeMachine Scope = "machine"
+)
+
+// ParseScope returns the Scope named s.
+func ParseScope(s string) (Scope, error) {
+	switch scope := Scope(strings.ToLower(s)); scope {
+	case ScopeGlobal, ScopeUnion, ScopeMachine:
+		return scope, nil
+	}
+	return "", fmt.Errorf("unknown scope %q, must be global, union or machine", s)
+}
+
+// ErrConflict is reported for rules given different policies by different
+// machines when they're imported as global rules.
+var ErrConflict = errors.New("machines have conflicting policies")
+
+// Machine holds the rules and settings of a machine-specific configuration.
+type Machine struct {
+	// ID is the Santa machine ID the configuration is served to, taken from
+	// its file name.
+	ID       string
+	Rules    []*apipb.Rule
+	Settings map[string]any
+}
+
+// Directory holds the configurations in a Moroz configs directory.
+type Directory struct {
+	Global         []*apipb.Rule
+	GlobalSettings map[string]any
+	// Machines are sorted by ID.
+	Machines []Machine
+}
+
+// ReadDirectory reads global.toml and every <machine-id>.toml file in dir.
+// Rules with an unknown rule type or policy are skipped and reported in a
+// rulehelpers.RecordErrors error alongside the rest of the directory.
+func ReadDirectory(dir string, useCustomMsgAsComment bool) (*Directory, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Directory{}
+	var recordErrs rulehelpers.RecordErrors
+	foundGlobal := false
+
+	// ReadDir returns the entries sorted by file name.
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(name), ".toml") {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		config, err := ParseConfigFromFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		rules, err := config.WorkshopRules(path, useCustomMsgAsComment)
+		var errs rulehelpers.RecordErrors
+		if errors.As(err, &errs) {
+			recordErrs = append(recordErrs, errs...)
+		} else if err != nil {
+			return nil, err
+		}
+
+		if strings.EqualFold(name, GlobalConfig) {
+			if foundGlobal {
+				return nil, fmt.Errorf("%s has more than one %s", dir, GlobalConfig)
+			}
+			d.Global = rules
+			d.GlobalSettings = config.Settings
+			foundGlobal = true
+			continue
+		}
+		d.Machines = append(d.Machines, Machine{
+			ID:       strings.TrimSuffix(name, filepath.Ext(name)),
+			Rules:    rules,
+			Settings: config.Settings,
+		})
+	}
+
+	if !foundGlobal {
+		return nil, fmt.Errorf("%s has no %s", dir, GlobalConfig)
+	}
+	return d, recordErrs.Err()
+}
+
+// Rules returns the rules to import for scope. Rules are matched on their
+// rule type and identifier. Since Moroz serves a machine its own
+// configuration instead of the global one, global rules missing from a
+// machine's configuration still apply to that machine once imported.
+func (d *Directory) Rules(scope Scope) ([]*apipb.Rule, error) {
+	rules := append([]*apipb.Rule{}, d.Global...)
+	if scope == ScopeGlobal {
+		return rules, nil
+	}
+
+	global := map[rulehelpers.Key]*apipb.Rule{}
+	for _, r := range d.Global {
+		global[rulehelpers.KeyOf(r)] = r
+	}
+
+	if scope == ScopeMachine {
+		for _, m := range d.Machines {
+			for _, r := range m.Rules {
+				if g, ok := global[rulehelpers.KeyOf(r)]; ok && g.GetPolicy() == r.GetPolicy() {
+					continue
+				}
+				r = proto.Clone(r).(*apipb.Rule)
+				r.Tag = "host:" + m.ID
+				rules = append(rules, r)
+			}
+		}
+		return rules, nil
+	}
+
+	var recordErrs rulehelpers.RecordErrors
+	conflicts := map[rulehelpers.Key]bool{}
+	added := map[rulehelpers.Key]*apipb.Rule{}
+	for _, m := range d.Machines {
+		for _, r := range m.Rules {
+			key := rulehelpers.KeyOf(r)
+			if _, ok := global[key]; ok {
+				continue
+			}
+			if a, ok := added[key]; ok {
+				if a.GetPolicy() != r.GetPolicy() && !conflicts[key] {
+					conflicts[key] = true
+					recordErrs = append(recordErrs, &rulehelpers.RecordError{
+						Location:   m.ID + ".toml",
+						Identifier: r.GetIdentifier(),
+						Err:        fmt.Errorf("%w: %s and %s", ErrConflict, a.GetPolicy(), r.GetPolicy()),
+					})
+				}
+				continue
+			}
+			added[key] = r
+			rules = append(rules, r)
+		}
+	}
+
+	// Conflicting rules are reported rather than imported with whichever
+	// policy was read first.
+	kept := rules[:0]
+	for _, r := range rules {
+		if !conflicts[rulehelpers.KeyOf(r)] {
+			kept = append(kept, r)
+		}
+	}
+	return kept, recordErrs.Err()
+}
+
+// MachineOnly is a rule found in machine-specific configurations but not in
+// the global one, and the machines it was found on.
+type MachineOnly struct {
+	Rule     *apipb.Rule
+	Machines []string
+}
+
+// MachineOnly returns the rules that are only in machine-specific
+// configurations, most widely used first. Rules whose policy differs from the
+// global rule are included.
+func (d *Directory) MachineOnly() []MachineOnly {
+	type ruleKey struct {
+		rulehelpers.Key
+		policy string
+	}
+	global := map[ruleKey]bool{}
+	for _, r := range d.Global {
+		global[ruleKey{rulehelpers.KeyOf(r), r.GetPolicy().String()}] = true
+	}
+
+	var only []MachineOnly
+	index := map[ruleKey]int{}
+	for _, m := range d.Machines {
+		for _, r := range m.Rules {
+			k := ruleKey{rulehelpers.KeyOf(r), r.GetPolicy().String()}
+			if global[k] {
+				continue
+			}
+			i, ok := index[k]
+			if !ok {
+				i = len(only)
+				index[k] = i
+				only = append(only, MachineOnly{Rule: r})
+			}
+			only[i].Machines = append(only[i].Machines, m.ID)
+		}
+	}
+
+	sort.SliceStable(only, func(i, j int) bool {
+		return len(only[i].Machines) > len(only[j].Machines)
+	})
+	return only
+}
+
+// WriteMachineOnlyReport writes the rules that are only in machine-specific
+// configurations to w as CSV.
+func WriteMachineOnlyReport(w io.Writer, only []MachineOnly) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"identifier", "type", "policy", "machine_count", "machines"}); err != nil {
+		return err
+	}
+	for _, m := range only {
+		err := writer.Write([]string{
+			m.Rule.GetIdentifier(),
+			m.Rule.GetRuleType().String(),
+			m.Rule.GetPolicy().String(),
+			strconv.Itoa(len(m.Machines)),
+			strings.Join(m.Machines, " "),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -1,5 +1,6 @@
 // Package morozconfig provides code for extracing rules from the Moroz TOML
-// file.
+// file, or from a Moroz configs directory holding a global.toml file and a
+// <machine-id>.toml file for each machine with its own configuration.
 //
 // An example file can be found in
 //
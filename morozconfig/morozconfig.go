@@ -0,0 +1,155 @@
+package morozconfig
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/pelletier/go-toml/v2"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// Rule represents a single rule from the configuration
+type Rule struct {
+	RuleType   string `toml:"rule_type"`
+	Policy     string `toml:"policy"`
+	Identifier string `toml:"identifier"`
+	CustomMsg  string `toml:"custom_msg,omitempty"`
+	CustomURL  string `toml:"custom_url,omitempty"`
+}
+
+// Config represents the overall configuration structure
+type Config struct {
+	Rules []Rule `toml:"rules"`
+
+	// Settings holds the other top-level keys, such as client_mode and
+	// batch_size, as decoded from TOML.
+	Settings map[string]any `toml:"-"`
+}
+
+// ToWorkshopRule converts the rule to Workshop format. If
+// useCustomMsgAsComment is set the custom message is also used as the comment.
+func (rule Rule) ToWorkshopRule(useCustomMsgAsComment bool) (*apipb.Rule, error) {
+	r, err := rulehelpers.NewRule(rule.RuleType, rule.Policy, rule.Identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	r.CustomMsg = rule.CustomMsg
+	r.CustomUrl = rule.CustomURL
+
+	if useCustomMsgAsComment {
+		r.Comment = rule.CustomMsg
+	}
+	return r, nil
+}
+
+// ParseRulesFromFile reads a moroz TOML configuration file and returns a slice
+// of rules. Rules with an unknown rule type or policy are skipped and reported
+// in a rulehelpers.RecordErrors error alongside the remaining rules.
+func ParseRulesFromFile(filePath string, useCustomMsgAsComment bool) ([]*apipb.Rule, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseRules(f, filePath, useCustomMsgAsComment)
+}
+
+// ParseRules is like ParseRulesFromFile but reads the configuration from r.
+// name identifies the configuration in record errors.
+func ParseRules(r io.Reader, name string, useCustomMsgAsComment bool) ([]*apipb.Rule, error) {
+	rules, _, err := parseRules(r, name, useCustomMsgAsComment)
+	return rules, err
+}
+
+func parseRules(r io.Reader, name string, useCustomMsgAsComment bool) ([]*apipb.Rule, rulehelpers.Locations, error) {
+	config, err := ParseConfig(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return config.workshopRules(name, useCustomMsgAsComment)
+}
+
+// ParseConfigFromFile reads a moroz TOML configuration file, including its
+// settings.
+func ParseConfigFromFile(filePath string) (*Config, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseConfig(f)
+}
+
+// ParseConfig is like ParseConfigFromFile but reads the configuration from r.
+func ParseConfig(r io.Reader) (*Config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := toml.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	if err := toml.Unmarshal(data, &config.Settings); err != nil {
+		return nil, err
+	}
+	delete(config.Settings, "rules")
+
+	return &config, nil
+}
+
+// WorkshopRules converts the rules of the configuration to Workshop format.
+// Rules with an unknown rule type or policy are skipped and reported in a
+// rulehelpers.RecordErrors error alongside the remaining rules. name
+// identifies the configuration in record errors.
+func (config *Config) WorkshopRules(name string, useCustomMsgAsComment bool) ([]*apipb.Rule, error) {
+	rules, _, err := config.workshopRules(name, useCustomMsgAsComment)
+	return rules, err
+}
+
+func (config *Config) workshopRules(name string, useCustomMsgAsComment bool) ([]*apipb.Rule, rulehelpers.Locations, error) {
+	rules := []*apipb.Rule{}
+	locs := rulehelpers.Locations{}
+	var recordErrs rulehelpers.RecordErrors
+
+	for i, rule := range config.Rules {
+		location := fmt.Sprintf("%s: rules[%d]", name, i)
+		r, err := rule.ToWorkshopRule(useCustomMsgAsComment)
+		if err != nil {
+			recordErrs = append(recordErrs, &rulehelpers.RecordError{
+				Location:   location,
+				Identifier: rule.Identifier,
+				Err:        err,
+			})
+			continue
+		}
+		rules = append(rules, r)
+		locs[r] = location
+	}
+
+	return rules, locs, recordErrs.Err()
+}
+
+// WriteRules writes rules to w as a moroz TOML configuration containing only
+// the rules table. Comments have no equivalent in moroz and are dropped.
+func WriteRules(w io.Writer, rules []*apipb.Rule) error {
+	config := Config{Rules: make([]Rule, 0, len(rules))}
+	for _, rule := range rules {
+		config.Rules = append(config.Rules, Rule{
+			RuleType:   rule.GetRuleType().String(),
+			Policy:     rule.GetPolicy().String(),
+			Identifier: rule.GetIdentifier(),
+			CustomMsg:  rule.GetCustomMsg(),
+			CustomURL:  rule.GetCustomUrl(),
+		})
+	}
+
+	return toml.NewEncoder(w).Encode(config)
+}
@@ -0,0 +1,287 @@
+package morozconfig_test
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/northpolesec/santa-rule-importer/morozconfig"
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/northpolesec/santa-rule-importer/source"
+	"github.com/shoenig/test"
+	"github.com/shoenig/test/must"
+
+	syncpb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/sync"
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+func TestParseRulesFromFile(t *testing.T) {
+	// Test with a valid file
+	rules, err := morozconfig.ParseRulesFromFile("testdata/global.toml", false)
+	must.NoError(t, err)
+
+	// Sort the rules by identifier since the TOML parser uses a map under the
+	// hood
+	slices.SortFunc(rules, func(a, b *apipb.Rule) int {
+		return strings.Compare(a.GetIdentifier(), b.GetIdentifier())
+	})
+
+	must.Eq(t, 2, len(rules))
+
+	test.Eq(t, syncpb.Policy_BLOCKLIST, rules[0].GetPolicy())
+	test.Eq(t, syncpb.RuleType_SIGNINGID, rules[0].GetRuleType())
+	test.Eq(t, "platform:com.apple.osacompile", rules[0].GetIdentifier())
+	test.Eq(t, "https://gist.github.com/pmarkowsky/bfa5840261351f506444b2d8541e9654",
+		rules[0].GetCustomUrl())
+	test.Eq(t, "osacompile is banned by policy", rules[0].GetCustomMsg())
+
+	test.Eq(t, syncpb.Policy_BLOCKLIST, rules[1].GetPolicy())
+	test.Eq(t, syncpb.RuleType_SIGNINGID, rules[1].GetRuleType())
+	test.Eq(t, "platform:com.apple.osascript", rules[1].GetIdentifier())
+	test.Eq(t, "https://www.youtube.com/watch?v=dQw4w9WgXcQ", rules[1].GetCustomUrl())
+	test.Eq(t, "Where does this go?", rules[1].GetCustomMsg())
+}
+
+func TestUsingCustomMsgAsComment(t *testing.T) {
+	// Test with a valid file
+	rules, err := morozconfig.ParseRulesFromFile("testdata/global.toml", true)
+	must.NoError(t, err)
+
+	// Sort the rules by identifier since the TOML parser uses a map under the
+	// hood
+	slices.SortFunc(rules, func(a, b *apipb.Rule) int {
+		return strings.Compare(a.GetIdentifier(), b.GetIdentifier())
+	})
+
+	must.Eq(t, 2, len(rules))
+	test.Eq(t, "osacompile is banned by policy", rules[0].GetComment())
+
+	// Ensure the comment and the custom_msg are the same
+	test.Eq(t, rules[0].GetCustomMsg(), rules[0].GetComment())
+
+	test.Eq(t, "Where does this go?", rules[1].GetComment())
+	// Ensure the comment and the custom_msg are the same
+	test.Eq(t, rules[1].GetCustomMsg(), rules[1].GetComment())
+}
+
+func TestParseRulesFromFileInvalidRules(t *testing.T) {
+	rules, err := morozconfig.ParseRulesFromFile("testdata/invalid.toml", false)
+
+	var recordErrs rulehelpers.RecordErrors
+	must.ErrorAs(t, err, &recordErrs)
+	must.Eq(t, 1, len(recordErrs))
+	test.Eq(t, "testdata/invalid.toml: rules[1]", recordErrs[0].Location)
+	test.Eq(t, "platform:com.apple.osacompile", recordErrs[0].Identifier)
+	test.ErrorContains(t, recordErrs[0], `unknown policy type: "DENY"`)
+
+	must.Eq(t, 1, len(rules))
+	test.Eq(t, "platform:com.apple.osascript", rules[0].GetIdentifier())
+}
+
+func TestWriteRulesRoundTrip(t *testing.T) {
+	rules := []*apipb.Rule{
+		{
+			RuleType:   syncpb.RuleType_SIGNINGID,
+			Policy:     syncpb.Policy_BLOCKLIST,
+			Identifier: "platform:com.apple.osascript",
+			CustomMsg:  "Where does this go?",
+			CustomUrl:  "https://www.youtube.com/watch?v=dQw4w9WgXcQ",
+		},
+		{
+			RuleType:   syncpb.RuleType_TEAMID,
+			Policy:     syncpb.Policy_SILENT_BLOCKLIST,
+			Identifier: "EQHXZ8M8AV",
+		},
+	}
+
+	var buf bytes.Buffer
+	must.NoError(t, morozconfig.WriteRules(&buf, rules))
+
+	path := filepath.Join(t.TempDir(), "global.toml")
+	must.NoError(t, os.WriteFile(path, buf.Bytes(), 0o600))
+
+	parsed, err := morozconfig.ParseRulesFromFile(path, false)
+	must.NoError(t, err)
+	must.Eq(t, 2, len(parsed))
+
+	test.Eq(t, syncpb.RuleType_SIGNINGID, parsed[0].GetRuleType())
+	test.Eq(t, syncpb.Policy_BLOCKLIST, parsed[0].GetPolicy())
+	test.Eq(t, "platform:com.apple.osascript", parsed[0].GetIdentifier())
+	test.Eq(t, "Where does this go?", parsed[0].GetCustomMsg())
+	test.Eq(t, "https://www.youtube.com/watch?v=dQw4w9WgXcQ", parsed[0].GetCustomUrl())
+
+	test.Eq(t, syncpb.RuleType_TEAMID, parsed[1].GetRuleType())
+	test.Eq(t, syncpb.Policy_SILENT_BLOCKLIST, parsed[1].GetPolicy())
+	test.Eq(t, "EQHXZ8M8AV", parsed[1].GetIdentifier())
+}
+
+func TestDetect(t *testing.T) {
+	data, err := os.ReadFile("testdata/global.toml")
+	must.NoError(t, err)
+
+	reg, ok := source.Detect(data)
+	must.True(t, ok)
+	test.Eq(t, "moroz", reg.Name)
+
+	_, ok = source.Detect([]byte("identifier,type,policy\n"))
+	test.False(t, ok)
+}
+
+const (
+	machineA = "8F7B2C4E-1D3A-4B5C-9E6F-0A1B2C3D4E5F"
+	machineB = "C0FFEE00-1234-5678-9ABC-DEF012345678"
+	clangd   = "6c58905785bccb8a0854cca5a646c4ea6b20e522c9b61de842a759919df002e7"
+)
+
+func TestReadDirectory(t *testing.T) {
+	dir, err := morozconfig.ReadDirectory("testdata/configs", false)
+	must.NoError(t, err)
+
+	must.Eq(t, 2, len(dir.Global))
+	must.Eq(t, 2, len(dir.Machines))
+	test.Eq(t, machineA, dir.Machines[0].ID)
+	test.Eq(t, 4, len(dir.Machines[0].Rules))
+	test.Eq(t, machineB, dir.Machines[1].ID)
+	test.Eq(t, "MONITOR", dir.Machines[1].Settings["client_mode"])
+	test.Eq(t, "LOCKDOWN", dir.GlobalSettings["client_mode"])
+
+	rules, err := dir.Rules(morozconfig.ScopeGlobal)
+	must.NoError(t, err)
+	test.Eq(t, 2, len(rules))
+
+	// The binary rule is allowed on one machine and blocked on the other.
+	rules, err = dir.Rules(morozconfig.ScopeUnion)
+	var recordErrs rulehelpers.RecordErrors
+	must.ErrorAs(t, err, &recordErrs)
+	must.Eq(t, 1, len(recordErrs))
+	test.Eq(t, clangd, recordErrs[0].Identifier)
+	test.ErrorIs(t, recordErrs[0], morozconfig.ErrConflict)
+	must.Eq(t, 3, len(rules))
+	test.Eq(t, "UBF8T346G9", rules[2].GetIdentifier())
+	test.Eq(t, "", rules[2].GetTag())
+
+	rules, err = dir.Rules(morozconfig.ScopeMachine)
+	must.NoError(t, err)
+	var tags []string
+	for _, r := range rules {
+		tags = append(tags, r.GetTag())
+	}
+	test.Eq(t, []string{
+		"", "",
+		"host:" + machineA, "host:" + machineA, "host:" + machineA,
+		"host:" + machineB, "host:" + machineB,
+	}, tags)
+	test.Eq(t, "platform:com.apple.osascript", rules[2].GetIdentifier())
+	test.Eq(t, syncpb.Policy_ALLOWLIST, rules[2].GetPolicy())
+
+	// Scoping copies the rules.
+	test.Eq(t, "", dir.Machines[0].Rules[1].GetTag())
+}
+
+func TestReadDirectoryWithoutGlobal(t *testing.T) {
+	_, err := morozconfig.ReadDirectory(t.TempDir(), false)
+	test.ErrorContains(t, err, "has no global.toml")
+}
+
+func TestReadDirectoryGlobalIgnoresCase(t *testing.T) {
+	global, err := os.ReadFile("testdata/configs/global.toml")
+	must.NoError(t, err)
+
+	dir := t.TempDir()
+	must.NoError(t, os.WriteFile(filepath.Join(dir, "Global.TOML"), global, 0o644))
+	d, err := morozconfig.ReadDirectory(dir, false)
+	must.NoError(t, err)
+	test.Eq(t, 2, len(d.Global))
+	test.Eq(t, 0, len(d.Machines))
+}
+
+func TestWriteMachineOnlyReport(t *testing.T) {
+	dir, err := morozconfig.ReadDirectory("testdata/configs", false)
+	must.NoError(t, err)
+
+	var buf bytes.Buffer
+	must.NoError(t, morozconfig.WriteMachineOnlyReport(&buf, dir.MachineOnly()))
+	test.Eq(t, `identifier,type,policy,machine_count,machines
+UBF8T346G9,TEAMID,ALLOWLIST,2,`+machineA+` `+machineB+`
+platform:com.apple.osascript,SIGNINGID,ALLOWLIST,1,`+machineA+`
+`+clangd+`,BINARY,ALLOWLIST,1,`+machineA+`
+`+clangd+`,BINARY,BLOCKLIST,1,`+machineB+`
+`, buf.String())
+}
+
+func TestOpenDirectory(t *testing.T) {
+	reg, ok := source.Lookup("moroz")
+	must.True(t, ok)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	reg.Driver.SetFlags(fs)
+	must.NoError(t, fs.Parse([]string{"--moroz-scope", "machine"}))
+
+	src, err := reg.Driver.Open("testdata/configs")
+	must.NoError(t, err)
+	rules, err := src.Rules(context.Background())
+	must.NoError(t, err)
+	test.Eq(t, 7, len(rules))
+
+	must.NoError(t, fs.Parse([]string{"--moroz-scope", "everything"}))
+	_, err = reg.Driver.Open("testdata/configs")
+	test.ErrorContains(t, err, `unknown scope "everything"`)
+}
+
+func TestMapSettings(t *testing.T) {
+	config, err := morozconfig.ParseConfigFromFile("testdata/global.toml")
+	must.NoError(t, err)
+	must.Eq(t, 2, len(config.Rules))
+	test.MapNotContainsKey(t, config.Settings, "rules")
+
+	settings, report := morozconfig.MapSettings(config.Settings)
+	test.Eq(t, syncpb.ClientMode_MONITOR, settings.GetClientMode())
+	test.Eq(t, 100, settings.GetBatchSize())
+	test.False(t, settings.GetEnableBundles())
+	test.NotNil(t, settings.EnableBundles)
+	test.True(t, settings.GetEnableTransitiveRules())
+	test.True(t, settings.GetEnableAllEventUpload())
+	test.Nil(t, settings.AllowedPathRegex)
+
+	var unmapped []string
+	for _, s := range report {
+		if !s.Migrated() {
+			unmapped = append(unmapped, s.Key)
+		}
+	}
+	test.Eq(t, []string{"clean_sync", "full_sync_interval"}, unmapped)
+}
+
+func TestMapSettingsInvalid(t *testing.T) {
+	config, err := morozconfig.ParseConfig(strings.NewReader(`
+client_mode = "PARANOID"
+batch_size = -1
+allowlist_regex = "^/opt/tools/.*"
+blacklist_regex = "^/Users/.*/Downloads/.*"
+`))
+	must.NoError(t, err)
+
+	settings, report := morozconfig.MapSettings(config.Settings)
+	test.Eq(t, "^/opt/tools/.*", settings.GetAllowedPathRegex())
+	test.Eq(t, "^/Users/.*/Downloads/.*", settings.GetBlockedPathRegex())
+	test.Eq(t, syncpb.ClientMode_UNKNOWN_CLIENT_MODE, settings.GetClientMode())
+	test.Nil(t, settings.BatchSize)
+
+	var buf bytes.Buffer
+	must.NoError(t, morozconfig.WriteSettingsReport(&buf, report))
+	test.Eq(t, `Settings: 2 migrated, 0 without a Workshop equivalent, 2 invalid
+
+Migrated:
+  + allowlist_regex = "^/opt/tools/.*" -> allowed_path_regex
+  + blacklist_regex = "^/Users/.*/Downloads/.*" -> blocked_path_regex
+
+Invalid:
+  ! batch_size: invalid setting: batch size must be a positive integer, got -1
+  ! client_mode: invalid setting: unknown client mode PARANOID
+`, buf.String())
+}
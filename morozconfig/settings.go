@@ -0,0 +1,185 @@
+package morozconfig
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	syncpb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/sync"
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// Setting describes how a Moroz setting is migrated to Workshop.
+type Setting struct {
+	Key   string
+	Value any
+
+	// Workshop is the Workshop sync setting the Moroz setting maps to. It is
+	// empty if Workshop has no equivalent.
+	Workshop string
+
+	// Note explains how to migrate a setting with no Workshop equivalent.
+	Note string
+
+	// Err is set if the value can't be migrated.
+	Err error
+}
+
+// Migrated reports whether the setting is applied to Workshop.
+func (s Setting) Migrated() bool {
+	return s.Workshop != "" && s.Err == nil
+}
+
+type settingMapping struct {
+	workshop string
+	apply    func(s *apipb.SyncSettings, value any) error
+}
+
+// settingMappings maps the Moroz settings with a Workshop equivalent to the
+// sync setting they set. The path regexes are also accepted under the
+// allowlist/blocklist and whitelist/blacklist names of older configurations.
+var settingMappings = map[string]settingMapping{
+	"client_mode":             {"client_mode", applyClientMode},
+	"batch_size":              {"batch_size", applyBatchSize},
+	"enable_bundles":          boolSetting("enable_bundles", func(s *apipb.SyncSettings) **bool { return &s.EnableBundles }),
+	"enable_transitive_rules": boolSetting("enable_transitive_rules", func(s *apipb.SyncSettings) **bool { return &s.EnableTransitiveRules }),
+	"enable_all_event_upload": boolSetting("enable_all_event_upload", func(s *apipb.SyncSettings) **bool { return &s.EnableAllEventUpload }),
+	"allowed_path_regex":      stringSetting("allowed_path_regex", func(s *apipb.SyncSettings) **string { return &s.AllowedPathRegex }),
+	"allowlist_regex":         stringSetting("allowed_path_regex", func(s *apipb.SyncSettings) **string { return &s.AllowedPathRegex }),
+	"whitelist_regex":         stringSetting("allowed_path_regex", func(s *apipb.SyncSettings) **string { return &s.AllowedPathRegex }),
+	"blocked_path_regex":      stringSetting("blocked_path_regex", func(s *apipb.SyncSettings) **string { return &s.BlockedPathRegex }),
+	"blocklist_regex":         stringSetting("blocked_path_regex", func(s *apipb.SyncSettings) **string { return &s.BlockedPathRegex }),
+	"blacklist_regex":         stringSetting("blocked_path_regex", func(s *apipb.SyncSettings) **string { return &s.BlockedPathRegex }),
+}
+
+// unmappedNotes explains how to migrate known Moroz settings that Workshop
+// has no sync setting for.
+var unmappedNotes = map[string]string{
+	"clean_sync":         "Workshop decides when clients need a clean sync",
+	"full_sync_interval": "set FullSyncInterval in the Santa configuration profile",
+	"machine_id":         "Workshop identifies machines by their own machine ID",
+}
+
+// ErrInvalidSetting is reported for settings whose value can't be migrated.
+var ErrInvalidSetting = errors.New("invalid setting")
+
+func applyClientMode(s *apipb.SyncSettings, value any) error {
+	str, _ := value.(string)
+	mode, ok := syncpb.ClientMode_value[strings.ToUpper(str)]
+	if !ok || mode == int32(syncpb.ClientMode_UNKNOWN_CLIENT_MODE) {
+		return fmt.Errorf("%w: unknown client mode %v", ErrInvalidSetting, value)
+	}
+	s.ClientMode = syncpb.ClientMode(mode)
+	return nil
+}
+
+func applyBatchSize(s *apipb.SyncSettings, value any) error {
+	n, ok := value.(int64)
+	if !ok || n < 1 || n > math.MaxUint32 {
+		return fmt.Errorf("%w: batch size must be a positive integer, got %v", ErrInvalidSetting, value)
+	}
+	s.BatchSize = proto.Uint32(uint32(n))
+	return nil
+}
+
+func boolSetting(workshop string, field func(s *apipb.SyncSettings) **bool) settingMapping {
+	return settingMapping{workshop, func(s *apipb.SyncSettings, value any) error {
+		b, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("%w: expected true or false, got %v", ErrInvalidSetting, value)
+		}
+		*field(s) = proto.Bool(b)
+		return nil
+	}}
+}
+
+func stringSetting(workshop string, field func(s *apipb.SyncSettings) **string) settingMapping {
+	return settingMapping{workshop, func(s *apipb.SyncSettings, value any) error {
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%w: expected a string, got %v", ErrInvalidSetting, value)
+		}
+		*field(s) = proto.String(str)
+		return nil
+	}}
+}
+
+// MapSettings converts the settings of a Moroz configuration to Workshop sync
+// settings. It returns how each setting was migrated, sorted by key. Settings
+// with no Workshop equivalent or an invalid value are left out of the sync
+// settings.
+func MapSettings(settings map[string]any) (*apipb.SyncSettings, []Setting) {
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	syncSettings := &apipb.SyncSettings{}
+	report := make([]Setting, 0, len(keys))
+	for _, key := range keys {
+		setting := Setting{Key: key, Value: settings[key]}
+		if m, ok := settingMappings[key]; ok {
+			setting.Workshop = m.workshop
+			setting.Err = m.apply(syncSettings, setting.Value)
+		} else {
+			setting.Note = unmappedNotes[key]
+		}
+		report = append(report, setting)
+	}
+
+	return syncSettings, report
+}
+
+// WriteSettingsReport writes a human-readable summary of how settings are
+// migrated to w.
+func WriteSettingsReport(w io.Writer, settings []Setting) error {
+	var migrated, unmapped, invalid []Setting
+	for _, s := range settings {
+		switch {
+		case s.Err != nil:
+			invalid = append(invalid, s)
+		case s.Workshop == "":
+			unmapped = append(unmapped, s)
+		default:
+			migrated = append(migrated, s)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Settings: %d migrated, %d without a Workshop equivalent, %d invalid\n",
+		len(migrated), len(unmapped), len(invalid))
+
+	if len(migrated) > 0 {
+		fmt.Fprintf(&b, "\nMigrated:\n")
+		for _, s := range migrated {
+			fmt.Fprintf(&b, "  + %s = %#v -> %s\n", s.Key, s.Value, s.Workshop)
+		}
+	}
+
+	if len(unmapped) > 0 {
+		fmt.Fprintf(&b, "\nNo Workshop equivalent:\n")
+		for _, s := range unmapped {
+			if s.Note == "" {
+				fmt.Fprintf(&b, "  - %s = %#v\n", s.Key, s.Value)
+			} else {
+				fmt.Fprintf(&b, "  - %s = %#v (%s)\n", s.Key, s.Value, s.Note)
+			}
+		}
+	}
+
+	if len(invalid) > 0 {
+		fmt.Fprintf(&b, "\nInvalid:\n")
+		for _, s := range invalid {
+			fmt.Fprintf(&b, "  ! %s: %v\n", s.Key, s.Err)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
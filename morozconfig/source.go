@@ -0,0 +1,78 @@
+package morozconfig
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/northpolesec/santa-rule-importer/internal/textutil"
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/northpolesec/santa-rule-importer/source"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+func init() {
+	source.Register(source.Registration{
+		Name:        "moroz",
+		Description: "Moroz TOML config file or configs directory",
+		Extensions:  []string{".toml"},
+		Detect:      rulesTable.Match,
+		Driver:      &driver{},
+	})
+}
+
+// rulesTable matches the start of an array of rule tables.
+var rulesTable = regexp.MustCompile(`(?m)^\s*\[\[\s*rules\s*\]\]`)
+
+type driver struct {
+	useCustomMsgAsComment bool
+	scope                 string
+	report                string
+}
+
+func (d *driver) SetFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&d.useCustomMsgAsComment, "use-custom-msg-as-comment", false, "Use custom message as comment (moroz only)")
+	fs.StringVar(&d.scope, "moroz-scope", string(ScopeGlobal), "Rules to import from a Moroz configs directory (global, union or machine)")
+	fs.StringVar(&d.report, "moroz-report", "", "Write the rules only found in machine-specific Moroz configs as CSV to this file, - for stderr")
+}
+
+func (d *driver) Open(path string) (source.Source, error) {
+	if fi, err := os.Stat(path); err != nil || !fi.IsDir() {
+		return source.OpenFile(d, path), nil
+	}
+
+	scope, err := ParseScope(d.scope)
+	if err != nil {
+		return nil, err
+	}
+
+	return source.Func(func(context.Context) ([]*apipb.Rule, error) {
+		dir, err := ReadDirectory(path, d.useCustomMsgAsComment)
+		var recordErrs rulehelpers.RecordErrors
+		if err != nil && !errors.As(err, &recordErrs) {
+			return nil, err
+		}
+
+		if d.report != "" {
+			only := dir.MachineOnly()
+			if err := textutil.WriteFile(d.report, func(w io.Writer) error { return WriteMachineOnlyReport(w, only) }); err != nil {
+				return nil, err
+			}
+		}
+
+		rules, err := dir.Rules(scope)
+		var scopeErrs rulehelpers.RecordErrors
+		if err != nil && !errors.As(err, &scopeErrs) {
+			return nil, err
+		}
+		return rules, append(recordErrs, scopeErrs...).Err()
+	}), nil
+}
+
+func (d *driver) Parse(r io.Reader, name string) ([]*apipb.Rule, rulehelpers.Locations, error) {
+	return parseRules(r, name, d.useCustomMsgAsComment)
+}
@@ -0,0 +1,4 @@
+// Package osquery reads osquery and Fleet query results for the santa_rules
+// table, de-duplicates the rules seen across hosts and converts them to
+// Workshop format.
+package osquery
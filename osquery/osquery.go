@@ -0,0 +1,399 @@
+package osquery
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/northpolesec/santa-rule-importer/internal/textutil"
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// Row is a row of the santa_rules table and the host it was read from.
+type Row struct {
+	Host          string
+	Identifier    string
+	Type          string
+	State         string
+	CustomMessage string
+}
+
+// hostColumns are the names used for the host of a row by osquery and Fleet,
+// in order of preference.
+var hostColumns = []string{"host_display_name", "host_hostname", "hostIdentifier", "host_identifier", "hostname", "host"}
+
+// states maps the rule states reported by osquery to the names accepted by
+// rulehelpers. Older osquery releases use Santa's old names.
+var states = map[string]string{
+	"whitelist":          "ALLOWLIST",
+	"blacklist":          "BLOCKLIST",
+	"denylist":           "BLOCKLIST",
+	"silent_blacklist":   "SILENT_BLOCKLIST",
+	"whitelist_compiler": "ALLOWLIST_COMPILER",
+}
+
+// ToWorkshopRule converts a santa_rules row to Workshop format.
+func (row Row) ToWorkshopRule() (*apipb.Rule, error) {
+	state := strings.TrimSpace(row.State)
+	if s, ok := states[strings.ToLower(state)]; ok {
+		state = s
+	}
+
+	r, err := rulehelpers.NewRule(strings.TrimSpace(row.Type), state, strings.TrimSpace(row.Identifier))
+	if err != nil {
+		return nil, err
+	}
+
+	r.CustomMsg = row.CustomMessage
+	return r, nil
+}
+
+// ReadRows reads query results in any of the formats produced by osquery and
+// Fleet:
+//
+//   - a JSON array of rows, as exported by Fleet or osqueryi --json
+//   - JSON lines with a host and its rows, as printed by fleetctl query
+//   - JSON lines from the osquery results log, in event or snapshot format
+//   - CSV with a header row, as exported by Fleet
+//
+// Rows without a host are counted as a single unnamed host.
+func ReadRows(r io.Reader) ([]Row, error) {
+	br := bufio.NewReader(r)
+	first, err := textutil.FirstByte(br)
+	if err != nil {
+		return nil, err
+	}
+
+	switch first {
+	case '[':
+		var results []map[string]any
+		if err := json.NewDecoder(br).Decode(&results); err != nil {
+			return nil, err
+		}
+		var rows []Row
+		for _, result := range results {
+			rows = append(rows, rowFromMap(result, ""))
+		}
+		return rows, nil
+	case '{':
+		return readJSONLines(br)
+	default:
+		return readCSV(br)
+	}
+}
+
+// readJSONLines reads fleetctl query output or an osquery results log. The
+// rows of differential results that were removed cancel the row added
+// earlier for the same host and rule, and a snapshot replaces every earlier
+// row of its host.
+func readJSONLines(r io.Reader) ([]Row, error) {
+	type rowKey struct {
+		host, ruleType, identifier string
+	}
+	keyOf := func(row Row) rowKey {
+		return rowKey{row.Host, strings.ToUpper(strings.TrimSpace(row.Type)), strings.TrimSpace(row.Identifier)}
+	}
+
+	var rows []Row
+	removed := map[int]bool{}
+	added := map[rowKey][]int{}
+	dec := json.NewDecoder(r)
+	for {
+		var line struct {
+			Host           string           `json:"host"`
+			Rows           []map[string]any `json:"rows"`
+			HostIdentifier string           `json:"hostIdentifier"`
+			Columns        map[string]any   `json:"columns"`
+			Snapshot       []map[string]any `json:"snapshot"`
+			Action         string           `json:"action"`
+		}
+		err := dec.Decode(&line)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		host := line.Host
+		if host == "" {
+			host = line.HostIdentifier
+		}
+		var lineRows []Row
+		if line.Snapshot != nil {
+			for key, indexes := range added {
+				if key.host == host {
+					for _, i := range indexes {
+						removed[i] = true
+					}
+					delete(added, key)
+				}
+			}
+		}
+		if line.Columns != nil {
+			row := rowFromMap(line.Columns, host)
+			if line.Action == "removed" {
+				key := keyOf(row)
+				if i := len(added[key]) - 1; i >= 0 {
+					removed[added[key][i]] = true
+					added[key] = added[key][:i]
+				}
+			} else {
+				lineRows = append(lineRows, row)
+			}
+		}
+		for _, result := range slices.Concat(line.Rows, line.Snapshot) {
+			lineRows = append(lineRows, rowFromMap(result, host))
+		}
+		for _, row := range lineRows {
+			key := keyOf(row)
+			added[key] = append(added[key], len(rows))
+			rows = append(rows, row)
+		}
+	}
+
+	kept := rows[:0]
+	for i, row := range rows {
+		if !removed[i] {
+			kept = append(kept, row)
+		}
+	}
+	return kept, nil
+}
+
+func readCSV(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV header: %w", err)
+	}
+
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result := make(map[string]any, len(header))
+		for i, col := range header {
+			if i < len(record) {
+				result[strings.TrimSpace(col)] = record[i]
+			}
+		}
+		rows = append(rows, rowFromMap(result, ""))
+	}
+}
+
+// rowFromMap reads a result row. Older osquery releases name the identifier
+// column shasum.
+func rowFromMap(result map[string]any, host string) Row {
+	str := func(key string) string {
+		switch v := result[key].(type) {
+		case string:
+			return v
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return ""
+	}
+
+	row := Row{
+		Host:          host,
+		Identifier:    str("identifier"),
+		Type:          str("type"),
+		State:         str("state"),
+		CustomMessage: str("custom_message"),
+	}
+	if row.Identifier == "" {
+		row.Identifier = str("shasum")
+	}
+	for _, col := range hostColumns {
+		if row.Host != "" {
+			break
+		}
+		row.Host = str(col)
+	}
+	return row
+}
+
+// Seen is a rule and the hosts it was seen on.
+type Seen struct {
+	Rule  *apipb.Rule
+	Hosts []string
+}
+
+// ErrConflict is reported for rules seen with different policies on
+// different hosts.
+var ErrConflict = errors.New("seen with conflicting policies")
+
+// Aggregate de-duplicates the rules in rows by rule type and identifier, in
+// the order they were first seen, and records the hosts each rule was seen
+// on. Rows that can't be converted are reported once per identifier in a
+// rulehelpers.RecordErrors error, as are rules seen with different policies,
+// which are skipped. name identifies the results in record errors.
+func Aggregate(rows []Row, name string) ([]Seen, error) {
+	type entry struct {
+		seen     Seen
+		policies map[string][]string
+	}
+	var order []rulehelpers.Key
+	entries := map[rulehelpers.Key]*entry{}
+
+	invalid := map[string][]string{}
+	var invalidOrder []string
+	invalidErrs := map[string]error{}
+
+	for _, row := range rows {
+		r, err := row.ToWorkshopRule()
+		if err != nil {
+			id := row.Identifier
+			if _, ok := invalid[id]; !ok {
+				invalidOrder = append(invalidOrder, id)
+				invalidErrs[id] = err
+			}
+			invalid[id] = appendHost(invalid[id], row.Host)
+			continue
+		}
+
+		key := rulehelpers.KeyOf(r)
+		e, ok := entries[key]
+		if !ok {
+			e = &entry{seen: Seen{Rule: r}, policies: map[string][]string{}}
+			entries[key] = e
+			order = append(order, key)
+		}
+		e.seen.Hosts = appendHost(e.seen.Hosts, row.Host)
+		policy := r.GetPolicy().String()
+		e.policies[policy] = appendHost(e.policies[policy], row.Host)
+	}
+
+	var recordErrs rulehelpers.RecordErrors
+	for _, id := range invalidOrder {
+		recordErrs = append(recordErrs, &rulehelpers.RecordError{
+			Location:   fmt.Sprintf("%s (%s)", name, pluralHosts(len(invalid[id]))),
+			Identifier: id,
+			Err:        invalidErrs[id],
+		})
+	}
+
+	var seen []Seen
+	for _, key := range order {
+		e := entries[key]
+		if len(e.policies) > 1 {
+			var policies []string
+			for policy, hosts := range e.policies {
+				policies = append(policies, fmt.Sprintf("%s on %s", policy, pluralHosts(len(hosts))))
+			}
+			sort.Strings(policies)
+			recordErrs = append(recordErrs, &rulehelpers.RecordError{
+				Location:   fmt.Sprintf("%s (%s)", name, pluralHosts(len(e.seen.Hosts))),
+				Identifier: key.Identifier,
+				Err:        fmt.Errorf("%w: %s", ErrConflict, strings.Join(policies, ", ")),
+			})
+			continue
+		}
+		seen = append(seen, e.seen)
+	}
+
+	return seen, recordErrs.Err()
+}
+
+func appendHost(hosts []string, host string) []string {
+	if slices.Contains(hosts, host) {
+		return hosts
+	}
+	return append(hosts, host)
+}
+
+func pluralHosts(n int) string {
+	if n == 1 {
+		return "1 host"
+	}
+	return fmt.Sprintf("%d hosts", n)
+}
+
+// Rules returns the rules of seen.
+func Rules(seen []Seen) []*apipb.Rule {
+	rules := make([]*apipb.Rule, 0, len(seen))
+	for _, s := range seen {
+		rules = append(rules, s.Rule)
+	}
+	return rules
+}
+
+// locations locates the rules of seen by the number of hosts they were seen
+// on, as record errors are.
+func locations(seen []Seen, name string) rulehelpers.Locations {
+	locs := make(rulehelpers.Locations, len(seen))
+	for _, s := range seen {
+		locs[s.Rule] = fmt.Sprintf("%s (%s)", name, pluralHosts(len(s.Hosts)))
+	}
+	return locs
+}
+
+// ParseRulesFromFile reads santa_rules query results and returns the rules
+// seen on any host, de-duplicated as described by Aggregate.
+func ParseRulesFromFile(filePath string) ([]*apipb.Rule, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseRules(f, filePath)
+}
+
+// ParseRules is like ParseRulesFromFile but reads the results from r. name
+// identifies the results in record errors.
+func ParseRules(r io.Reader, name string) ([]*apipb.Rule, error) {
+	rows, err := ReadRows(r)
+	if err != nil {
+		return nil, err
+	}
+	seen, err := Aggregate(rows, name)
+	return Rules(seen), err
+}
+
+// WriteReport writes the number of hosts each rule was seen on to w as CSV,
+// most widely seen first.
+func WriteReport(w io.Writer, seen []Seen) error {
+	sorted := slices.Clone(seen)
+	slices.SortStableFunc(sorted, func(a, b Seen) int {
+		return len(b.Hosts) - len(a.Hosts)
+	})
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"identifier", "type", "policy", "host_count", "hosts"}); err != nil {
+		return err
+	}
+	for _, s := range sorted {
+		err := writer.Write([]string{
+			s.Rule.GetIdentifier(),
+			s.Rule.GetRuleType().String(),
+			s.Rule.GetPolicy().String(),
+			strconv.Itoa(len(s.Hosts)),
+			strings.Join(s.Hosts, " "),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
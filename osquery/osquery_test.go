@@ -0,0 +1,149 @@
+package osquery_test
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/shoenig/test"
+	"github.com/shoenig/test/must"
+
+	"github.com/northpolesec/santa-rule-importer/osquery"
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/northpolesec/santa-rule-importer/source"
+
+	syncpb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/sync"
+)
+
+const (
+	clangd = "6c58905785bccb8a0854cca5a646c4ea6b20e522c9b61de842a759919df002e7"
+	cert   = "2aa4b9973b7ba07add447ee4da8b5337c3ee2c3a991911e80e7282e8a751fc32"
+)
+
+func TestParseRulesFromFileJSON(t *testing.T) {
+	rules, err := osquery.ParseRulesFromFile("testdata/fleet.json")
+
+	var recordErrs rulehelpers.RecordErrors
+	must.ErrorAs(t, err, &recordErrs)
+	must.Eq(t, 2, len(recordErrs))
+	test.Eq(t, "testdata/fleet.json (2 hosts)", recordErrs[0].Location)
+	test.Eq(t, cert, recordErrs[0].Identifier)
+	test.Eq(t, "platform:com.apple.osascript", recordErrs[1].Identifier)
+	test.ErrorIs(t, recordErrs[1], osquery.ErrConflict)
+	test.StrContains(t, recordErrs[1].Error(), "ALLOWLIST on 1 host, BLOCKLIST on 1 host")
+
+	must.Eq(t, 2, len(rules))
+	test.Eq(t, clangd, rules[0].GetIdentifier())
+	test.Eq(t, syncpb.RuleType_BINARY, rules[0].GetRuleType())
+	test.Eq(t, syncpb.Policy_ALLOWLIST, rules[0].GetPolicy())
+	test.Eq(t, "EQHXZ8M8AV", rules[1].GetIdentifier())
+	test.Eq(t, syncpb.RuleType_TEAMID, rules[1].GetRuleType())
+}
+
+func TestParseRulesFromFileCSV(t *testing.T) {
+	rules, err := osquery.ParseRulesFromFile("testdata/fleet.csv")
+	must.NoError(t, err)
+
+	must.Eq(t, 3, len(rules))
+	test.Eq(t, syncpb.RuleType_CERTIFICATE, rules[2].GetRuleType())
+	test.Eq(t, syncpb.Policy_BLOCKLIST, rules[2].GetPolicy())
+	test.Eq(t, "Blocked certificate", rules[2].GetCustomMsg())
+}
+
+func TestReadRowsResultsLog(t *testing.T) {
+	f, err := os.Open("testdata/osqueryd.results.log")
+	must.NoError(t, err)
+	defer f.Close()
+
+	rows, err := osquery.ReadRows(f)
+	must.NoError(t, err)
+
+	// The removed row is skipped and the snapshot rows use the legacy
+	// shasum column.
+	must.Eq(t, 3, len(rows))
+	test.Eq(t, osquery.Row{Host: "alice-mbp", Identifier: clangd, Type: "Binary", State: "Whitelist"}, rows[0])
+	test.Eq(t, "carol-mbp", rows[2].Host)
+	test.Eq(t, cert, rows[2].Identifier)
+
+	seen, err := osquery.Aggregate(rows, "results")
+	must.NoError(t, err)
+	must.Eq(t, 2, len(seen))
+	test.Eq(t, []string{"alice-mbp", "carol-mbp"}, seen[0].Hosts)
+	test.Eq(t, syncpb.Policy_ALLOWLIST, seen[0].Rule.GetPolicy())
+	test.Eq(t, syncpb.Policy_BLOCKLIST, seen[1].Rule.GetPolicy())
+}
+
+func TestReadRowsDifferentialRemoved(t *testing.T) {
+	input := `{"hostIdentifier":"alice-mbp","columns":{"identifier":"EQHXZ8M8AV","type":"TeamID","state":"Allowlist"},"action":"added"}
+{"hostIdentifier":"bob-mbp","columns":{"identifier":"EQHXZ8M8AV","type":"TeamID","state":"Allowlist"},"action":"added"}
+{"hostIdentifier":"alice-mbp","columns":{"identifier":"EQHXZ8M8AV","type":"TeamID","state":"Allowlist"},"action":"removed"}
+{"hostIdentifier":"bob-mbp","columns":{"identifier":"EQHXZ8M8AV","type":"TeamID","state":"Allowlist"},"action":"removed"}
+{"hostIdentifier":"bob-mbp","columns":{"identifier":"EQHXZ8M8AV","type":"TeamID","state":"Blocklist"},"action":"added"}
+`
+	rows, err := osquery.ReadRows(strings.NewReader(input))
+	must.NoError(t, err)
+
+	// The rule removed from alice's Mac is gone, and bob's was replaced.
+	must.Eq(t, 1, len(rows))
+	test.Eq(t, "bob-mbp", rows[0].Host)
+	test.Eq(t, "Blocklist", rows[0].State)
+}
+
+func TestReadRowsSnapshotReplacesHost(t *testing.T) {
+	input := `{"hostIdentifier":"alice-mbp","columns":{"identifier":"EQHXZ8M8AV","type":"TeamID","state":"Allowlist"},"action":"added"}
+{"hostIdentifier":"bob-mbp","columns":{"identifier":"EQHXZ8M8AV","type":"TeamID","state":"Allowlist"},"action":"added"}
+{"hostIdentifier":"alice-mbp","snapshot":[{"identifier":"BJ4HAAB9B3","type":"TeamID","state":"Blocklist"}],"action":"snapshot"}
+{"hostIdentifier":"bob-mbp","snapshot":[],"action":"snapshot"}
+`
+	rows, err := osquery.ReadRows(strings.NewReader(input))
+	must.NoError(t, err)
+
+	// Alice's rows are replaced by her snapshot, and bob's snapshot is empty.
+	must.Eq(t, 1, len(rows))
+	test.Eq(t, "alice-mbp", rows[0].Host)
+	test.Eq(t, "BJ4HAAB9B3", rows[0].Identifier)
+}
+
+func TestReadRowsFleetctl(t *testing.T) {
+	input := `{"host":"alice-mbp","rows":[{"identifier":"EQHXZ8M8AV","type":"TeamID","state":"Allowlist","custom_message":""}]}
+{"host":"bob-mbp","rows":[{"identifier":"EQHXZ8M8AV","type":"TeamID","state":"Allowlist","custom_message":""}]}
+`
+	rows, err := osquery.ReadRows(strings.NewReader(input))
+	must.NoError(t, err)
+
+	seen, err := osquery.Aggregate(rows, "stdin")
+	must.NoError(t, err)
+	must.Eq(t, 1, len(seen))
+	test.Eq(t, []string{"alice-mbp", "bob-mbp"}, seen[0].Hosts)
+}
+
+func TestWriteReport(t *testing.T) {
+	f, err := os.Open("testdata/fleet.csv")
+	must.NoError(t, err)
+	defer f.Close()
+
+	rows, err := osquery.ReadRows(f)
+	must.NoError(t, err)
+	seen, err := osquery.Aggregate(rows, "fleet.csv")
+	must.NoError(t, err)
+
+	var buf bytes.Buffer
+	must.NoError(t, osquery.WriteReport(&buf, seen))
+	test.Eq(t, `identifier,type,policy,host_count,hosts
+`+clangd+`,BINARY,ALLOWLIST,2,alice-mbp bob-mbp
+EQHXZ8M8AV,TEAMID,ALLOWLIST,1,alice-mbp
+`+cert+`,CERTIFICATE,BLOCKLIST,1,bob-mbp
+`, buf.String())
+}
+
+func TestDetect(t *testing.T) {
+	for _, path := range []string{"testdata/fleet.json", "testdata/fleet.csv", "testdata/osqueryd.results.log"} {
+		data, err := os.ReadFile(path)
+		must.NoError(t, err)
+
+		reg, ok := source.Detect(data)
+		must.True(t, ok, must.Sprint(path))
+		test.Eq(t, "osquery", reg.Name)
+	}
+}
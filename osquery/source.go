@@ -0,0 +1,74 @@
+package osquery
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"io"
+	"slices"
+
+	"github.com/northpolesec/santa-rule-importer/internal/textutil"
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/northpolesec/santa-rule-importer/source"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+func init() {
+	source.Register(source.Registration{
+		Name:        "osquery",
+		Description: "osquery or Fleet santa_rules query results (JSON or CSV)",
+		Detect:      detect,
+		Driver:      &driver{},
+	})
+}
+
+type driver struct {
+	report string
+}
+
+func (d *driver) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&d.report, "osquery-report", "", "Write the number of hosts each rule was seen on as CSV to this file, - for stderr (osquery only)")
+}
+
+func (d *driver) Open(path string) (source.Source, error) {
+	return source.OpenFile(d, path), nil
+}
+
+func (d *driver) Parse(r io.Reader, name string) ([]*apipb.Rule, rulehelpers.Locations, error) {
+	rows, err := ReadRows(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seen, err := Aggregate(rows, name)
+	var recordErrs rulehelpers.RecordErrors
+	if err != nil && !errors.As(err, &recordErrs) {
+		return nil, nil, err
+	}
+
+	if d.report != "" {
+		if rerr := textutil.WriteFile(d.report, func(w io.Writer) error { return WriteReport(w, seen) }); rerr != nil {
+			return nil, nil, rerr
+		}
+	}
+	return Rules(seen), locations(seen, name), err
+}
+
+// detect reports whether head holds santa_rules results: JSON or CSV with
+// state and type columns and an identifier or shasum column.
+func detect(head []byte) bool {
+	trimmed := bytes.TrimSpace(head)
+	if bytes.HasPrefix(trimmed, []byte("[")) || bytes.HasPrefix(trimmed, []byte("{")) {
+		hasColumn := func(col string) bool {
+			return bytes.Contains(trimmed, []byte(`"`+col+`"`))
+		}
+		return hasColumn("state") && hasColumn("type") && (hasColumn("identifier") || hasColumn("shasum"))
+	}
+
+	line, _, _ := bytes.Cut(head, []byte("\n"))
+	header, err := csv.NewReader(bytes.NewReader(bytes.TrimSpace(line))).Read()
+	return err == nil && slices.Contains(header, "state") && slices.Contains(header, "type") &&
+		(slices.Contains(header, "identifier") || slices.Contains(header, "shasum"))
+}
@@ -0,0 +1,162 @@
+package rudolph
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// Rudolph CSV column names
+const (
+	ColIdentifier  = "identifier"
+	ColType        = "type"
+	ColPolicy      = "policy"
+	ColCustomMsg   = "custom_msg"
+	ColDescription = "description"
+)
+
+// Rule is a single row of a Rudolph CSV export.
+type Rule struct {
+	Identifier  string
+	Type        string
+	Policy      string
+	CustomMsg   string
+	Description string
+}
+
+// ToWorkshopRule converts a Rudolph rule to Workshop format. The description
+// is used as the comment.
+func (rule Rule) ToWorkshopRule() (*apipb.Rule, error) {
+	r, err := rulehelpers.NewRule(rule.Type, rule.Policy, rule.Identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	r.CustomMsg = rule.CustomMsg
+	r.Comment = rule.Description
+	return r, nil
+}
+
+// ParseRulesFromFile reads a Rudolph CSV export and returns a slice of rules.
+// Rows with an unknown rule type or policy are skipped and reported in a
+// rulehelpers.RecordErrors error alongside the remaining rules.
+func ParseRulesFromFile(filePath string) ([]*apipb.Rule, error) {
+	// Open the CSV file
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParseRules(file, filePath)
+}
+
+// ParseRules is like ParseRulesFromFile but reads the export from r. name
+// identifies the export in record errors.
+func ParseRules(r io.Reader, name string) ([]*apipb.Rule, error) {
+	rules, _, err := parseRules(r, name)
+	return rules, err
+}
+
+func parseRules(r io.Reader, name string) ([]*apipb.Rule, rulehelpers.Locations, error) {
+	// Create a new CSV reader
+	reader := csv.NewReader(r)
+
+	// Read the header row
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading CSV header: %w", err)
+	}
+
+	// Map column indices
+	colIndices := make(map[string]int)
+	for i, col := range header {
+		colIndices[col] = i
+	}
+
+	// Required columns
+	requiredCols := []string{ColIdentifier, ColType, ColPolicy}
+	for _, col := range requiredCols {
+		if _, ok := colIndices[col]; !ok {
+			return nil, nil, fmt.Errorf("missing required column: %s", col)
+		}
+	}
+
+	rules := []*apipb.Rule{}
+	locs := rulehelpers.Locations{}
+	var recordErrs rulehelpers.RecordErrors
+
+	// Read data rows
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Extract rule fields from the row
+		rule := Rule{
+			Identifier: row[colIndices[ColIdentifier]],
+			Type:       row[colIndices[ColType]],
+			Policy:     row[colIndices[ColPolicy]],
+		}
+
+		// Optional fields
+		if idx, ok := colIndices[ColCustomMsg]; ok && idx < len(row) {
+			rule.CustomMsg = row[idx]
+		}
+		if idx, ok := colIndices[ColDescription]; ok && idx < len(row) {
+			rule.Description = row[idx]
+		}
+
+		line, _ := reader.FieldPos(0)
+		location := fmt.Sprintf("%s:%d", name, line)
+		r, err := rule.ToWorkshopRule()
+		if err != nil {
+			recordErrs = append(recordErrs, &rulehelpers.RecordError{
+				Location:   location,
+				Identifier: rule.Identifier,
+				Err:        err,
+			})
+			continue
+		}
+
+		rules = append(rules, r)
+		locs[r] = location
+	}
+
+	return rules, locs, recordErrs.Err()
+}
+
+// WriteRules writes rules to w as a Rudolph CSV export. Custom URLs have no
+// column in the Rudolph format and are dropped.
+func WriteRules(w io.Writer, rules []*apipb.Rule) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write([]string{ColIdentifier, ColType, ColPolicy, ColCustomMsg, ColDescription}); err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		err := writer.Write([]string{
+			rule.GetIdentifier(),
+			rule.GetRuleType().String(),
+			rule.GetPolicy().String(),
+			rule.GetCustomMsg(),
+			rule.GetComment(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
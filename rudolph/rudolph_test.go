@@ -0,0 +1,169 @@
+package rudolph_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shoenig/test"
+	"github.com/shoenig/test/must"
+
+	"github.com/northpolesec/santa-rule-importer/rudolph"
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/northpolesec/santa-rule-importer/source"
+
+	syncpb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/sync"
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+func TestParseRulesFromFile(t *testing.T) {
+	// Test with a valid CSV file
+	rules, err := rudolph.ParseRulesFromFile("testdata/rudolph.csv")
+	must.NoError(t, err)
+
+	test.Eq(t, 6, len(rules))
+
+	// Check the first rule
+	test.Eq(t, "d84db96af8c2e60ac4c851a21ec460f6f84e0235beb17d24a78712b9b021ed57", rules[0].GetIdentifier())
+	test.Eq(t, "CERTIFICATE", rules[0].RuleType.String())
+	test.Eq(t, "ALLOWLIST", rules[0].Policy.String())
+	test.Eq(t, "", rules[0].CustomMsg)
+	test.Eq(t, "Software Signing by Apple Inc.", rules[0].Comment)
+
+	// Check the second rule
+	test.Eq(t, "d292f56f78effeb715382f3578b3716309da04e31589b23b68c3750edd526660", rules[1].Identifier)
+	test.Eq(t, "CERTIFICATE", rules[1].RuleType.String())
+	test.Eq(t, "ALLOWLIST", rules[1].Policy.String())
+	test.Eq(t, "", rules[1].CustomMsg)
+	test.Eq(t, "Developer ID Application: Zoom Video Communications, Inc. (BJ4HAAB9B3)", rules[1].Comment)
+
+	// Check the third rule
+	test.Eq(t, "96f18e09d65445985c7df5df74ef152a0bc42e8934175a626180d9700c343e7b", rules[2].Identifier)
+	test.Eq(t, "CERTIFICATE", rules[2].RuleType.String())
+	test.Eq(t, "ALLOWLIST", rules[2].Policy.String())
+	test.Eq(t, "", rules[2].CustomMsg)
+	test.Eq(t, "Developer ID Application: Mozilla Corporation (43AQ936H96)", rules[2].Comment)
+
+	// Check the fourth rule
+	test.Eq(t, "61977d6006459c4cefe9b988a453589946224957bfc07b262cd7ca1b7a61e04e", rules[3].Identifier)
+	test.Eq(t, "CERTIFICATE", rules[3].RuleType.String())
+	test.Eq(t, "ALLOWLIST", rules[3].Policy.String())
+	test.Eq(t, "", rules[3].CustomMsg)
+	test.Eq(t, "Apple Mac OS Application Signing", rules[3].Comment)
+
+	// Check the fifth rule
+	test.Eq(t, "345a8e098bd04794aaeefda8c9ef56a0bf3d3706d67d35bc0e23f11bb3bffce5", rules[4].Identifier)
+	test.Eq(t, "CERTIFICATE", rules[4].RuleType.String())
+	test.Eq(t, "ALLOWLIST", rules[4].Policy.String())
+	test.Eq(t, "", rules[4].CustomMsg)
+	test.Eq(t, "Developer ID Application: Google, Inc. (EQHXZ8M8AV)", rules[4].Comment)
+
+	// Check the sixth rule
+	test.Eq(t, "2401d9aee4e3601e12529f8e48e889533630f1ceb8adb49c976fe5c93c9df5e7", rules[5].Identifier)
+	test.Eq(t, "CERTIFICATE", rules[5].RuleType.String())
+	test.Eq(t, "ALLOWLIST", rules[5].Policy.String())
+	test.Eq(t, "", rules[5].CustomMsg)
+	test.Eq(t, "Developer ID Application: Slack Technologies, Inc. (BQR82RBBHL), by Slack Technologies, Inc. (BQR82RBBHL)", rules[5].Comment)
+}
+
+func TestParseRulesFromFileInvalidRows(t *testing.T) {
+	rules, err := rudolph.ParseRulesFromFile("testdata/rudolph_invalid.csv")
+
+	var recordErrs rulehelpers.RecordErrors
+	must.ErrorAs(t, err, &recordErrs)
+	must.Eq(t, 2, len(recordErrs))
+
+	test.Eq(t, "testdata/rudolph_invalid.csv:3", recordErrs[0].Location)
+	test.Eq(t, "d292f56f78effeb715382f3578b3716309da04e31589b23b68c3750edd526660", recordErrs[0].Identifier)
+	test.ErrorContains(t, recordErrs[0], "unknown policy type")
+
+	test.Eq(t, "testdata/rudolph_invalid.csv:4", recordErrs[1].Location)
+	test.ErrorContains(t, recordErrs[1], "unknown rule type")
+
+	// The valid row is still returned
+	must.Eq(t, 1, len(rules))
+	test.Eq(t, "d84db96af8c2e60ac4c851a21ec460f6f84e0235beb17d24a78712b9b021ed57", rules[0].GetIdentifier())
+}
+
+func TestParseRules(t *testing.T) {
+	csv := "identifier,type,policy,custom_msg,description\n" +
+		"EQHXZ8M8AV,TEAMID,BLOCKLIST,Blocked,Google\n" +
+		"BJ4HAAB9B3,TEAMID,DENY,,\n"
+
+	rules, err := rudolph.ParseRules(strings.NewReader(csv), "stdin")
+
+	var recordErrs rulehelpers.RecordErrors
+	must.ErrorAs(t, err, &recordErrs)
+	must.Eq(t, 1, len(recordErrs))
+	test.Eq(t, "stdin:3", recordErrs[0].Location)
+
+	must.Eq(t, 1, len(rules))
+	test.Eq(t, "EQHXZ8M8AV", rules[0].GetIdentifier())
+	test.Eq(t, "Blocked", rules[0].GetCustomMsg())
+	test.Eq(t, "Google", rules[0].GetComment())
+}
+
+func TestWriteRulesRoundTrip(t *testing.T) {
+	rules := []*apipb.Rule{
+		{
+			RuleType:   syncpb.RuleType_CERTIFICATE,
+			Policy:     syncpb.Policy_ALLOWLIST,
+			Identifier: "d84db96af8c2e60ac4c851a21ec460f6f84e0235beb17d24a78712b9b021ed57",
+			Comment:    "Software Signing by Apple Inc.",
+		},
+		{
+			RuleType:   syncpb.RuleType_BINARY,
+			Policy:     syncpb.Policy_BLOCKLIST,
+			Identifier: "6c58905785bccb8a0854cca5a646c4ea6b20e522c9b61de842a759919df002e7",
+			CustomMsg:  "Blocked, contact IT",
+			Comment:    "clangd, \"pinned\" version",
+		},
+	}
+
+	var buf bytes.Buffer
+	must.NoError(t, rudolph.WriteRules(&buf, rules))
+
+	path := filepath.Join(t.TempDir(), "rules.csv")
+	must.NoError(t, os.WriteFile(path, buf.Bytes(), 0o600))
+
+	parsed, err := rudolph.ParseRulesFromFile(path)
+	must.NoError(t, err)
+	must.Eq(t, 2, len(parsed))
+
+	test.Eq(t, "CERTIFICATE", parsed[0].RuleType.String())
+	test.Eq(t, "ALLOWLIST", parsed[0].Policy.String())
+	test.Eq(t, "Software Signing by Apple Inc.", parsed[0].Comment)
+
+	test.Eq(t, "BINARY", parsed[1].RuleType.String())
+	test.Eq(t, "BLOCKLIST", parsed[1].Policy.String())
+	test.Eq(t, "Blocked, contact IT", parsed[1].CustomMsg)
+	test.Eq(t, "clangd, \"pinned\" version", parsed[1].Comment)
+}
+
+func TestDetect(t *testing.T) {
+	data, err := os.ReadFile("testdata/rudolph.csv")
+	must.NoError(t, err)
+
+	reg, ok := source.Detect(data)
+	must.True(t, ok)
+	test.Eq(t, "rudolph", reg.Name)
+
+	_, ok = source.Detect([]byte("[[rules]]\n"))
+	test.False(t, ok)
+}
+
+func TestSourceLocations(t *testing.T) {
+	reg, ok := source.Lookup("rudolph")
+	must.True(t, ok)
+	src, err := reg.Driver.Open("testdata/rudolph.csv")
+	must.NoError(t, err)
+	rules, err := src.Rules(context.Background())
+	must.NoError(t, err)
+
+	locator, ok := src.(source.Locator)
+	must.True(t, ok)
+	test.Eq(t, "testdata/rudolph.csv:3", locator.Locations()[rules[1]])
+}
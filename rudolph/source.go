@@ -0,0 +1,53 @@
+package rudolph
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"io"
+	"slices"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/northpolesec/santa-rule-importer/source"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+func init() {
+	source.Register(source.Registration{
+		Name:        "rudolph",
+		Description: "Rudolph CSV export",
+		Extensions:  []string{".csv"},
+		Detect:      detect,
+		Driver:      driver{},
+	})
+}
+
+type driver struct{}
+
+func (driver) SetFlags(*flag.FlagSet) {}
+
+func (d driver) Open(path string) (source.Source, error) {
+	return source.OpenFile(d, path), nil
+}
+
+func (driver) Parse(r io.Reader, name string) ([]*apipb.Rule, rulehelpers.Locations, error) {
+	return parseRules(r, name)
+}
+
+// detect reports whether the first line of head is a CSV header with the
+// required Rudolph columns.
+func detect(head []byte) bool {
+	line, _, _ := bufio.NewReader(bytes.NewReader(head)).ReadLine()
+	header, err := csv.NewReader(bytes.NewReader(line)).Read()
+	if err != nil {
+		return false
+	}
+	for _, col := range []string{ColIdentifier, ColType, ColPolicy} {
+		if !slices.Contains(header, col) {
+			return false
+		}
+	}
+	return true
+}
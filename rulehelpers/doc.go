@@ -0,0 +1,4 @@
+// Package rulehelpers provides helper functions for mapping the rule types and
+// policies found in source files to the Workshop API, and for reporting the
+// source records that could not be mapped.
+package rulehelpers
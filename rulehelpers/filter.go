@@ -0,0 +1,60 @@
+package rulehelpers
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/northpolesec/santa-rule-importer/internal/textutil"
+
+	syncpb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/sync"
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// Filter selects rules by rule type and policy. An empty list matches every
+// value.
+type Filter struct {
+	RuleTypes []syncpb.RuleType
+	Policies  []syncpb.Policy
+}
+
+// ParseFilter builds a Filter from comma separated lists of rule types and
+// policies, using the same names accepted by GetRuleType and GetPolicyType.
+func ParseFilter(ruleTypes, policies string) (Filter, error) {
+	var filter Filter
+	var errs []error
+
+	for _, rt := range textutil.SplitList(ruleTypes) {
+		ruleType, err := GetRuleType(rt)
+		errs = append(errs, err)
+		filter.RuleTypes = append(filter.RuleTypes, ruleType)
+	}
+	for _, p := range textutil.SplitList(policies) {
+		policy, err := GetPolicyType(p)
+		errs = append(errs, err)
+		filter.Policies = append(filter.Policies, policy)
+	}
+
+	return filter, errors.Join(errs...)
+}
+
+// Match reports whether rule is selected by the filter.
+func (f Filter) Match(rule *apipb.Rule) bool {
+	if len(f.RuleTypes) > 0 && !slices.Contains(f.RuleTypes, rule.GetRuleType()) {
+		return false
+	}
+	if len(f.Policies) > 0 && !slices.Contains(f.Policies, rule.GetPolicy()) {
+		return false
+	}
+	return true
+}
+
+// Apply returns the rules selected by the filter.
+func (f Filter) Apply(rules []*apipb.Rule) []*apipb.Rule {
+	var matched []*apipb.Rule
+	for _, rule := range rules {
+		if f.Match(rule) {
+			matched = append(matched, rule)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,45 @@
+package rulehelpers_test
+
+import (
+	"testing"
+
+	"github.com/shoenig/test"
+	"github.com/shoenig/test/must"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+
+	syncpb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/sync"
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+func TestParseFilter(t *testing.T) {
+	filter, err := rulehelpers.ParseFilter("teamid, signingid", "BLOCKLIST")
+	must.NoError(t, err)
+	test.Eq(t, []syncpb.RuleType{syncpb.RuleType_TEAMID, syncpb.RuleType_SIGNINGID}, filter.RuleTypes)
+	test.Eq(t, []syncpb.Policy{syncpb.Policy_BLOCKLIST}, filter.Policies)
+
+	filter, err = rulehelpers.ParseFilter("", "")
+	must.NoError(t, err)
+	test.Eq(t, rulehelpers.Filter{}, filter)
+
+	_, err = rulehelpers.ParseFilter("BUNDLE", "DENY")
+	test.ErrorContains(t, err, "unknown rule type")
+	test.ErrorContains(t, err, "unknown policy type")
+}
+
+func TestFilterApply(t *testing.T) {
+	rules := []*apipb.Rule{
+		{RuleType: syncpb.RuleType_TEAMID, Policy: syncpb.Policy_ALLOWLIST, Identifier: "EQHXZ8M8AV"},
+		{RuleType: syncpb.RuleType_TEAMID, Policy: syncpb.Policy_BLOCKLIST, Identifier: "BJ4HAAB9B3"},
+		{RuleType: syncpb.RuleType_SIGNINGID, Policy: syncpb.Policy_BLOCKLIST, Identifier: "platform:com.apple.osascript"},
+	}
+
+	test.Eq(t, 3, len(rulehelpers.Filter{}.Apply(rules)))
+
+	matched := rulehelpers.Filter{
+		RuleTypes: []syncpb.RuleType{syncpb.RuleType_TEAMID},
+		Policies:  []syncpb.Policy{syncpb.Policy_BLOCKLIST},
+	}.Apply(rules)
+	must.Eq(t, 1, len(matched))
+	test.Eq(t, "BJ4HAAB9B3", matched[0].GetIdentifier())
+}
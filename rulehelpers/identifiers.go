@@ -0,0 +1,97 @@
+package rulehelpers
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+
+	syncpb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/sync"
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+var (
+	sha256Re   = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	cdhashRe   = regexp.MustCompile(`^[0-9a-f]{40}$`)
+	teamIDRe   = regexp.MustCompile(`^[0-9A-Z]{10}$`)
+	bundleIDRe = regexp.MustCompile(`^[^\s:]+$`)
+)
+
+// NormalizeIdentifier trims surrounding whitespace from an identifier and
+// normalizes its case for the given rule type: hashes are lowercased, team IDs
+// are uppercased and signing IDs have their team ID prefix uppercased (or
+// "platform" lowercased). An error is returned if the identifier does not have
+// the expected shape for the rule type; the trimmed identifier is returned
+// either way.
+func NormalizeIdentifier(ruleType syncpb.RuleType, identifier string) (string, error) {
+	identifier = strings.TrimSpace(identifier)
+	if identifier == "" {
+		return identifier, errors.New("empty identifier")
+	}
+
+	switch ruleType {
+	case syncpb.RuleType_BINARY, syncpb.RuleType_CERTIFICATE:
+		normalized := strings.ToLower(identifier)
+		if !sha256Re.MatchString(normalized) {
+			return identifier, fmt.Errorf("%s identifier must be a SHA-256 hash of 64 hex characters", ruleType)
+		}
+		return normalized, nil
+	case syncpb.RuleType_CDHASH:
+		normalized := strings.ToLower(identifier)
+		if !cdhashRe.MatchString(normalized) {
+			return identifier, errors.New("CDHASH identifier must be 40 hex characters")
+		}
+		return normalized, nil
+	case syncpb.RuleType_TEAMID:
+		normalized := strings.ToUpper(identifier)
+		if !teamIDRe.MatchString(normalized) {
+			return identifier, errors.New("TEAMID identifier must be 10 alphanumeric characters")
+		}
+		return normalized, nil
+	case syncpb.RuleType_SIGNINGID:
+		prefix, bundleID, ok := strings.Cut(identifier, ":")
+		if !ok || !bundleIDRe.MatchString(bundleID) {
+			return identifier, errors.New("SIGNINGID identifier must have the form TEAMID:bundle.id or platform:bundle.id")
+		}
+		if strings.EqualFold(prefix, "platform") {
+			return "platform:" + bundleID, nil
+		}
+		prefix = strings.ToUpper(prefix)
+		if !teamIDRe.MatchString(prefix) {
+			return identifier, fmt.Errorf("SIGNINGID identifier has an invalid team ID %q", prefix)
+		}
+		return prefix + ":" + bundleID, nil
+	default:
+		return identifier, fmt.Errorf("unknown rule type: %s", ruleType)
+	}
+}
+
+// ValidateIdentifiers returns the rules whose identifiers are well formed,
+// with their identifiers normalized. rules is left unchanged: rules whose
+// identifiers are normalized are copied. Each malformed identifier is reported
+// in the returned RecordErrors, located by locs.
+func ValidateIdentifiers(rules []*apipb.Rule, locs Locations) ([]*apipb.Rule, RecordErrors) {
+	valid := make([]*apipb.Rule, 0, len(rules))
+	var recordErrs RecordErrors
+
+	for i, rule := range rules {
+		normalized, err := NormalizeIdentifier(rule.GetRuleType(), rule.GetIdentifier())
+		if err != nil {
+			recordErrs = append(recordErrs, &RecordError{
+				Location:   locs.Of(i, rule),
+				Identifier: normalized,
+				Err:        err,
+			})
+			continue
+		}
+		if normalized != rule.GetIdentifier() {
+			rule = proto.Clone(rule).(*apipb.Rule)
+			rule.Identifier = normalized
+		}
+		valid = append(valid, rule)
+	}
+
+	return valid, recordErrs
+}
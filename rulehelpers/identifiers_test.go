@@ -0,0 +1,133 @@
+package rulehelpers_test
+
+import (
+	"testing"
+
+	"github.com/shoenig/test"
+	"github.com/shoenig/test/must"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+
+	syncpb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/sync"
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+func TestNormalizeIdentifier(t *testing.T) {
+	cases := []struct {
+		name     string
+		ruleType syncpb.RuleType
+		in       string
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "binary uppercase with whitespace",
+			ruleType: syncpb.RuleType_BINARY,
+			in:       " 6C58905785BCCB8A0854CCA5A646C4EA6B20E522C9B61DE842A759919DF002E7\n",
+			want:     "6c58905785bccb8a0854cca5a646c4ea6b20e522c9b61de842a759919df002e7",
+		},
+		{
+			name:     "binary too short",
+			ruleType: syncpb.RuleType_BINARY,
+			in:       "6c58905785bccb8a",
+			wantErr:  "64 hex characters",
+		},
+		{
+			name:     "certificate not hex",
+			ruleType: syncpb.RuleType_CERTIFICATE,
+			in:       "zz58905785bccb8a0854cca5a646c4ea6b20e522c9b61de842a759919df002e7",
+			wantErr:  "64 hex characters",
+		},
+		{
+			name:     "cdhash",
+			ruleType: syncpb.RuleType_CDHASH,
+			in:       "A1B2C3D4E5F6A1B2C3D4E5F6A1B2C3D4E5F6A1B2",
+			want:     "a1b2c3d4e5f6a1b2c3d4e5f6a1b2c3d4e5f6a1b2",
+		},
+		{
+			name:     "cdhash sha256 length",
+			ruleType: syncpb.RuleType_CDHASH,
+			in:       "6c58905785bccb8a0854cca5a646c4ea6b20e522c9b61de842a759919df002e7",
+			wantErr:  "40 hex characters",
+		},
+		{
+			name:     "teamid lowercase",
+			ruleType: syncpb.RuleType_TEAMID,
+			in:       "eqhxz8m8av",
+			want:     "EQHXZ8M8AV",
+		},
+		{
+			name:     "teamid too long",
+			ruleType: syncpb.RuleType_TEAMID,
+			in:       "EQHXZ8M8AV1",
+			wantErr:  "10 alphanumeric characters",
+		},
+		{
+			name:     "signingid with team id",
+			ruleType: syncpb.RuleType_SIGNINGID,
+			in:       "eqhxz8m8av:com.google.Chrome",
+			want:     "EQHXZ8M8AV:com.google.Chrome",
+		},
+		{
+			name:     "signingid platform",
+			ruleType: syncpb.RuleType_SIGNINGID,
+			in:       "Platform:com.apple.osascript",
+			want:     "platform:com.apple.osascript",
+		},
+		{
+			name:     "signingid without prefix",
+			ruleType: syncpb.RuleType_SIGNINGID,
+			in:       "com.apple.osascript",
+			wantErr:  "TEAMID:bundle.id or platform:bundle.id",
+		},
+		{
+			name:     "signingid bad team id",
+			ruleType: syncpb.RuleType_SIGNINGID,
+			in:       "Google:com.google.Chrome",
+			wantErr:  "invalid team ID",
+		},
+		{
+			name:     "empty",
+			ruleType: syncpb.RuleType_TEAMID,
+			in:       "   ",
+			wantErr:  "empty identifier",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := rulehelpers.NormalizeIdentifier(tc.ruleType, tc.in)
+			if tc.wantErr != "" {
+				test.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			must.NoError(t, err)
+			test.Eq(t, tc.want, got)
+		})
+	}
+}
+
+func TestValidateIdentifiers(t *testing.T) {
+	rules := []*apipb.Rule{
+		{RuleType: syncpb.RuleType_TEAMID, Identifier: " eqhxz8m8av "},
+		{RuleType: syncpb.RuleType_BINARY, Identifier: "hash123"},
+		{RuleType: syncpb.RuleType_SIGNINGID, Identifier: "platform:com.apple.osascript"},
+	}
+
+	valid, recordErrs := rulehelpers.ValidateIdentifiers(rules, nil)
+
+	must.Eq(t, 2, len(valid))
+	test.Eq(t, "EQHXZ8M8AV", valid[0].GetIdentifier())
+	test.Eq(t, "platform:com.apple.osascript", valid[1].GetIdentifier())
+	test.Eq(t, " eqhxz8m8av ", rules[0].GetIdentifier())
+	test.True(t, valid[1] == rules[2])
+
+	must.Eq(t, 1, len(recordErrs))
+	test.Eq(t, "rule 1", recordErrs[0].Location)
+	test.Eq(t, "hash123", recordErrs[0].Identifier)
+
+	locs := rulehelpers.Locations{rules[1]: "rules.csv:3"}
+	_, recordErrs = rulehelpers.ValidateIdentifiers(rules, locs)
+	must.Eq(t, 1, len(recordErrs))
+	test.Eq(t, "rules.csv:3", recordErrs[0].Location)
+}
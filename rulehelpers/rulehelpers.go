@@ -0,0 +1,133 @@
+package rulehelpers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	syncpb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/sync"
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// GetPolicyType maps a string to a syncpb.Policy type or returns an error if
+// it's unknown.
+func GetPolicyType(policy string) (syncpb.Policy, error) {
+	switch strings.ToUpper(policy) {
+	case "ALLOWLIST", "ALLOW":
+		return syncpb.Policy_ALLOWLIST, nil
+	case "BLOCK", "BLOCKLIST":
+		return syncpb.Policy_BLOCKLIST, nil
+	case "SILENT_BLOCKLIST":
+		return syncpb.Policy_SILENT_BLOCKLIST, nil
+	case "ALLOWLIST_COMPILER":
+		return syncpb.Policy_ALLOWLIST_COMPILER, nil
+	default:
+		return syncpb.Policy_POLICY_UNKNOWN, fmt.Errorf("unknown policy type: %q", policy)
+	}
+}
+
+// GetRuleType maps a string to a syncpb.RuleType type or returns an error if
+// it's unknown.
+func GetRuleType(ruleType string) (syncpb.RuleType, error) {
+	switch strings.ToUpper(ruleType) {
+	case "CDHASH":
+		return syncpb.RuleType_CDHASH, nil
+	case "SHA256", "BINARY":
+		return syncpb.RuleType_BINARY, nil
+	case "SIGNINGID":
+		return syncpb.RuleType_SIGNINGID, nil
+	case "CERTIFICATE":
+		return syncpb.RuleType_CERTIFICATE, nil
+	case "TEAMID":
+		return syncpb.RuleType_TEAMID, nil
+	default:
+		return syncpb.RuleType_RULETYPE_UNKNOWN, fmt.Errorf("unknown rule type: %q", ruleType)
+	}
+}
+
+// Key uniquely identifies a rule in a Workshop instance. Rules with the same
+// rule type and identifier but different tags can coexist.
+type Key struct {
+	RuleType   syncpb.RuleType
+	Identifier string
+	Tag        string
+}
+
+// KeyOf returns the Key for a rule.
+func KeyOf(rule *apipb.Rule) Key {
+	return Key{
+		RuleType:   rule.GetRuleType(),
+		Identifier: rule.GetIdentifier(),
+		Tag:        rule.GetTag(),
+	}
+}
+
+// NewRule builds a rule from the string forms of its rule type and policy,
+// returning every mapping error rather than just the first.
+func NewRule(ruleType, policy, identifier string) (*apipb.Rule, error) {
+	rt, rtErr := GetRuleType(ruleType)
+	p, pErr := GetPolicyType(policy)
+	if err := errors.Join(rtErr, pErr); err != nil {
+		return nil, err
+	}
+
+	return &apipb.Rule{
+		RuleType:   rt,
+		Policy:     p,
+		Identifier: identifier,
+	}, nil
+}
+
+// RecordError describes a source record that could not be converted to a rule.
+// Location identifies the record within its source, e.g. a CSV line number,
+// an array index or a server-side ID.
+type RecordError struct {
+	Location   string
+	Identifier string
+	Err        error
+}
+
+func (e *RecordError) Error() string {
+	if e.Identifier == "" {
+		return fmt.Sprintf("%s: %v", e.Location, e.Err)
+	}
+	return fmt.Sprintf("%s (%s): %v", e.Location, e.Identifier, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// Locations maps rules to the location of the record they were read from,
+// in the same form as RecordError.Location, so that problems found once a
+// source has been read can still be reported against its records.
+type Locations map[*apipb.Rule]string
+
+// Of returns the location of rule, the i-th rule read from its source. Rules
+// without a recorded location are located by i.
+func (l Locations) Of(i int, rule *apipb.Rule) string {
+	if loc, ok := l[rule]; ok {
+		return loc
+	}
+	return fmt.Sprintf("rule %d", i)
+}
+
+// RecordErrors collects every invalid record found in a source. Parsers return
+// it alongside the rules that were converted successfully so that callers can
+// decide whether to abort or skip the invalid records.
+type RecordErrors []*RecordError
+
+func (e RecordErrors) Error() string {
+	if len(e) == 1 {
+		return "1 invalid record: " + e[0].Error()
+	}
+	return fmt.Sprintf("%d invalid records, first: %v", len(e), e[0])
+}
+
+// Err returns e as an error, or nil if it is empty.
+func (e RecordErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
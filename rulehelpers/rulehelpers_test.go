@@ -0,0 +1,67 @@
+package rulehelpers_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shoenig/test"
+	"github.com/shoenig/test/must"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+
+	syncpb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/sync"
+)
+
+func TestGetPolicyType(t *testing.T) {
+	p, err := rulehelpers.GetPolicyType("allow")
+	must.NoError(t, err)
+	test.Eq(t, syncpb.Policy_ALLOWLIST, p)
+
+	p, err = rulehelpers.GetPolicyType("BLOCK")
+	must.NoError(t, err)
+	test.Eq(t, syncpb.Policy_BLOCKLIST, p)
+
+	p, err = rulehelpers.GetPolicyType("silent_blocklist")
+	must.NoError(t, err)
+	test.Eq(t, syncpb.Policy_SILENT_BLOCKLIST, p)
+
+	p, err = rulehelpers.GetPolicyType("ALLOWLIST_COMPILER")
+	must.NoError(t, err)
+	test.Eq(t, syncpb.Policy_ALLOWLIST_COMPILER, p)
+
+	_, err = rulehelpers.GetPolicyType("DENY")
+	test.ErrorContains(t, err, `unknown policy type: "DENY"`)
+}
+
+func TestGetRuleType(t *testing.T) {
+	rt, err := rulehelpers.GetRuleType("sha256")
+	must.NoError(t, err)
+	test.Eq(t, syncpb.RuleType_BINARY, rt)
+
+	_, err = rulehelpers.GetRuleType("BUNDLE")
+	test.ErrorContains(t, err, `unknown rule type: "BUNDLE"`)
+}
+
+func TestNewRuleReportsAllErrors(t *testing.T) {
+	_, err := rulehelpers.NewRule("BUNDLE", "DENY", "abc")
+	test.ErrorContains(t, err, "unknown rule type")
+	test.ErrorContains(t, err, "unknown policy type")
+}
+
+func TestRecordErrors(t *testing.T) {
+	var errs rulehelpers.RecordErrors
+	must.NoError(t, errs.Err())
+
+	errs = append(errs, &rulehelpers.RecordError{
+		Location:   "rules.csv:3",
+		Identifier: "abc",
+		Err:        errors.New("unknown policy type"),
+	})
+	test.EqError(t, errs.Err(), "1 invalid record: rules.csv:3 (abc): unknown policy type")
+
+	errs = append(errs, &rulehelpers.RecordError{
+		Location: "rules.csv:4",
+		Err:      errors.New("unknown rule type"),
+	})
+	test.EqError(t, errs.Err(), "2 invalid records, first: rules.csv:3 (abc): unknown policy type")
+}
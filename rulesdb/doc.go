@@ -0,0 +1,4 @@
+// Package rulesdb reads the rules stored in a copy of Santa's local rules.db
+// SQLite database and converts them to Workshop format. The SQLite driver
+// requires cgo; binaries built without it report an error for rules.db files.
+package rulesdb
@@ -0,0 +1,231 @@
+package rulesdb
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// Rule states as stored by Santa in the state column.
+const (
+	StateAllow               = 1
+	StateBlock               = 2
+	StateSilentBlock         = 3
+	StateRemove              = 4
+	StateAllowCompiler       = 5
+	StateAllowTransitive     = 6
+	StateAllowLocalBinary    = 7
+	StateAllowLocalSigningID = 8
+	StateCEL                 = 9
+)
+
+// Rule types as stored by Santa in the type column.
+const (
+	TypeCDHash      = 500
+	TypeBinary      = 1000
+	TypeSigningID   = 2000
+	TypeCertificate = 3000
+	TypeTeamID      = 4000
+)
+
+// stateNames maps states to the policy names accepted by rulehelpers. States
+// that only make sense on the machine that created them are mapped to names
+// rulehelpers rejects, so they are reported as invalid records.
+var stateNames = map[int]string{
+	StateAllow:               "ALLOWLIST",
+	StateBlock:               "BLOCKLIST",
+	StateSilentBlock:         "SILENT_BLOCKLIST",
+	StateRemove:              "REMOVE",
+	StateAllowCompiler:       "ALLOWLIST_COMPILER",
+	StateAllowLocalBinary:    "ALLOWLIST_LOCAL_BINARY",
+	StateAllowLocalSigningID: "ALLOWLIST_LOCAL_SIGNINGID",
+	StateCEL:                 "CEL",
+}
+
+// typeNames maps rule types to the names accepted by rulehelpers.
+var typeNames = map[int]string{
+	TypeCDHash:      "CDHASH",
+	TypeBinary:      "BINARY",
+	TypeSigningID:   "SIGNINGID",
+	TypeCertificate: "CERTIFICATE",
+	TypeTeamID:      "TEAMID",
+}
+
+// Rule is a row of the rules table.
+type Rule struct {
+	Identifier string
+	State      int
+	Type       int
+	CustomMsg  string
+	CustomURL  string
+	Comment    string
+}
+
+// ToWorkshopRule converts a rules.db row to Workshop format.
+func (rule Rule) ToWorkshopRule() (*apipb.Rule, error) {
+	state, ok := stateNames[rule.State]
+	if !ok {
+		state = fmt.Sprint(rule.State)
+	}
+	ruleType, ok := typeNames[rule.Type]
+	if !ok {
+		ruleType = fmt.Sprint(rule.Type)
+	}
+
+	r, err := rulehelpers.NewRule(ruleType, state, rule.Identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	r.CustomMsg = rule.CustomMsg
+	r.CustomUrl = rule.CustomURL
+	r.Comment = rule.Comment
+	return r, nil
+}
+
+// ParseRulesFromFile reads the rules table of a rules.db file and returns a
+// slice of rules. Transitive allow rules are specific to the machine that
+// created them and are skipped. Rows with a state or rule type that can't be
+// imported are skipped and reported in a rulehelpers.RecordErrors error
+// alongside the remaining rules.
+func ParseRulesFromFile(filePath string) ([]*apipb.Rule, error) {
+	rules, _, err := parse(filePath, filePath)
+	return rules, err
+}
+
+// ParseRules is like ParseRulesFromFile but reads the database from r, which
+// is copied to a temporary file first. name identifies the database in record
+// errors.
+func ParseRules(r io.Reader, name string) ([]*apipb.Rule, error) {
+	rules, _, err := parseRules(r, name)
+	return rules, err
+}
+
+func parseRules(r io.Reader, name string) ([]*apipb.Rule, rulehelpers.Locations, error) {
+	f, err := os.CreateTemp("", "rules-*.db")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return parse(f.Name(), name)
+}
+
+func parse(filePath, name string) ([]*apipb.Rule, rulehelpers.Locations, error) {
+	if errNoSQLite != nil {
+		return nil, nil, errNoSQLite
+	}
+
+	// SQLite reports a missing file as "unable to open database file", so
+	// check it exists first for a clearer error.
+	if _, err := os.Stat(filePath); err != nil {
+		return nil, nil, err
+	}
+
+	// Open the database read-only so the copy is never modified.
+	db, err := sql.Open("sqlite3", (&url.URL{Scheme: "file", Opaque: filePath, RawQuery: "mode=ro"}).String())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer db.Close()
+
+	query, err := selectRules(db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read rules table: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []*apipb.Rule{}
+	locs := rulehelpers.Locations{}
+	var recordErrs rulehelpers.RecordErrors
+
+	for rows.Next() {
+		var rowID int64
+		var rule Rule
+		var customMsg, customURL, comment sql.NullString
+		if err := rows.Scan(&rowID, &rule.Identifier, &rule.State, &rule.Type, &customMsg, &customURL, &comment); err != nil {
+			return nil, nil, err
+		}
+		rule.CustomMsg = customMsg.String
+		rule.CustomURL = customURL.String
+		rule.Comment = comment.String
+
+		if rule.State == StateAllowTransitive {
+			continue
+		}
+
+		location := fmt.Sprintf("%s: rowid %d", name, rowID)
+		r, err := rule.ToWorkshopRule()
+		if err != nil {
+			recordErrs = append(recordErrs, &rulehelpers.RecordError{
+				Location:   location,
+				Identifier: rule.Identifier,
+				Err:        err,
+			})
+			continue
+		}
+		rules = append(rules, r)
+		locs[r] = location
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return rules, locs, recordErrs.Err()
+}
+
+// selectRules builds the query for the rules table. Columns added in later
+// Santa releases are read as NULL from databases that don't have them.
+func selectRules(db *sql.DB) (string, error) {
+	rows, err := db.Query("SELECT name FROM pragma_table_info('rules')")
+	if err != nil {
+		return "", fmt.Errorf("failed to read rules table: %w", err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return "", err
+		}
+		columns = append(columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	if len(columns) == 0 {
+		return "", fmt.Errorf("no rules table found, is this a Santa rules.db?")
+	}
+
+	selected := []string{"rowid", "identifier", "state", "type"}
+	for _, column := range []string{"custommsg", "customurl", "comment"} {
+		if slices.Contains(columns, column) {
+			selected = append(selected, column)
+		} else {
+			selected = append(selected, "NULL")
+		}
+	}
+	return "SELECT " + strings.Join(selected, ", ") + " FROM rules ORDER BY rowid", nil
+}
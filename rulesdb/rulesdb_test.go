@@ -0,0 +1,126 @@
+//go:build cgo
+
+package rulesdb_test
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shoenig/test"
+	"github.com/shoenig/test/must"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/northpolesec/santa-rule-importer/rulesdb"
+	"github.com/northpolesec/santa-rule-importer/source"
+
+	syncpb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/sync"
+)
+
+// createDB writes a rules.db with the given rules table schema and rows.
+func createDB(t *testing.T, schema string, rows ...[]any) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "rules.db")
+	db, err := sql.Open("sqlite3", path)
+	must.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec(schema)
+	must.NoError(t, err)
+	for _, row := range rows {
+		_, err = db.Exec("INSERT INTO rules (identifier, state, type, custommsg) VALUES (?, ?, ?, ?)", row...)
+		must.NoError(t, err)
+	}
+	return path
+}
+
+const schema = `CREATE TABLE 'rules' (
+	'identifier' TEXT NOT NULL,
+	'state' INTEGER NOT NULL,
+	'type' INTEGER NOT NULL,
+	'custommsg' TEXT,
+	'timestamp' INTEGER,
+	'customurl' TEXT,
+	'comment' TEXT
+)`
+
+func TestParseRulesFromFile(t *testing.T) {
+	path := createDB(t, schema,
+		[]any{"EQHXZ8M8AV", rulesdb.StateAllow, rulesdb.TypeTeamID, nil},
+		[]any{"platform:com.apple.osascript", rulesdb.StateBlock, rulesdb.TypeSigningID, "Blocked by IT"},
+		[]any{"d84db96af8c2e60ac4c851a21ec460f6f84e0235beb17d24a78712b9b021ed57", rulesdb.StateAllowTransitive, rulesdb.TypeBinary, nil},
+		[]any{"2aa4b9973b7ba07add447ee4da8b5337c3ee2c3a991911e80e7282e8a751fc32", rulesdb.StateAllowLocalBinary, rulesdb.TypeBinary, nil},
+	)
+
+	rules, err := rulesdb.ParseRulesFromFile(path)
+
+	var recordErrs rulehelpers.RecordErrors
+	must.ErrorAs(t, err, &recordErrs)
+	must.Eq(t, 1, len(recordErrs))
+	test.Eq(t, path+": rowid 4", recordErrs[0].Location)
+	test.ErrorContains(t, recordErrs[0], "ALLOWLIST_LOCAL_BINARY")
+
+	// The transitive rule is skipped without being reported.
+	must.Eq(t, 2, len(rules))
+	test.Eq(t, "EQHXZ8M8AV", rules[0].GetIdentifier())
+	test.Eq(t, syncpb.RuleType_TEAMID, rules[0].GetRuleType())
+	test.Eq(t, syncpb.Policy_ALLOWLIST, rules[0].GetPolicy())
+	test.Eq(t, "", rules[0].GetCustomMsg())
+
+	test.Eq(t, syncpb.RuleType_SIGNINGID, rules[1].GetRuleType())
+	test.Eq(t, syncpb.Policy_BLOCKLIST, rules[1].GetPolicy())
+	test.Eq(t, "Blocked by IT", rules[1].GetCustomMsg())
+}
+
+func TestParseRulesFromFileOldSchema(t *testing.T) {
+	// Databases from older Santa releases have no customurl or comment.
+	path := createDB(t, `CREATE TABLE 'rules' (
+		'identifier' TEXT NOT NULL,
+		'state' INTEGER NOT NULL,
+		'type' INTEGER NOT NULL,
+		'custommsg' TEXT
+	)`, []any{"EQHXZ8M8AV", rulesdb.StateSilentBlock, rulesdb.TypeTeamID, "msg"})
+
+	rules, err := rulesdb.ParseRulesFromFile(path)
+	must.NoError(t, err)
+	must.Eq(t, 1, len(rules))
+	test.Eq(t, syncpb.Policy_SILENT_BLOCKLIST, rules[0].GetPolicy())
+	test.Eq(t, "msg", rules[0].GetCustomMsg())
+}
+
+func TestParseRulesFromFileErrors(t *testing.T) {
+	_, err := rulesdb.ParseRulesFromFile(filepath.Join(t.TempDir(), "missing.db"))
+	test.ErrorIs(t, err, os.ErrNotExist)
+
+	path := filepath.Join(t.TempDir(), "empty.db")
+	db, err := sql.Open("sqlite3", path)
+	must.NoError(t, err)
+	_, err = db.Exec("CREATE TABLE other (id INTEGER)")
+	must.NoError(t, err)
+	must.NoError(t, db.Close())
+
+	_, err = rulesdb.ParseRulesFromFile(path)
+	test.ErrorContains(t, err, "no rules table")
+}
+
+func TestParseRules(t *testing.T) {
+	path := createDB(t, schema, []any{"EQHXZ8M8AV", rulesdb.StateBlock, rulesdb.TypeTeamID, nil})
+
+	f, err := os.Open(path)
+	must.NoError(t, err)
+	defer f.Close()
+
+	head := make([]byte, 100)
+	_, err = f.ReadAt(head, 0)
+	must.NoError(t, err)
+	reg, ok := source.Detect(head)
+	must.True(t, ok)
+	test.Eq(t, "rulesdb", reg.Name)
+
+	rules, err := rulesdb.ParseRules(f, "stdin")
+	must.NoError(t, err)
+	must.Eq(t, 1, len(rules))
+	test.Eq(t, syncpb.Policy_BLOCKLIST, rules[0].GetPolicy())
+}
@@ -0,0 +1,61 @@
+package rulesdb
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/northpolesec/santa-rule-importer/source"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+func init() {
+	source.Register(source.Registration{
+		Name:        "rulesdb",
+		Description: "Santa rules.db SQLite database",
+		Extensions:  []string{".db"},
+		Detect:      detect,
+		Driver:      driver{},
+	})
+}
+
+type driver struct{}
+
+func (driver) SetFlags(*flag.FlagSet) {}
+
+func (d driver) Open(path string) (source.Source, error) {
+	if path == source.Stdin {
+		return source.OpenFile(d, path), nil
+	}
+	return &fileSource{path: path}, nil
+}
+
+func (driver) Parse(r io.Reader, name string) ([]*apipb.Rule, rulehelpers.Locations, error) {
+	return parseRules(r, name)
+}
+
+// fileSource reads a database in place, rather than copying it as Parse does.
+type fileSource struct {
+	path string
+	locs rulehelpers.Locations
+}
+
+func (s *fileSource) Rules(context.Context) ([]*apipb.Rule, error) {
+	rules, locs, err := parse(s.path, s.path)
+	s.locs = locs
+	return rules, err
+}
+
+func (s *fileSource) Locations() rulehelpers.Locations {
+	return s.locs
+}
+
+// sqliteHeader starts every SQLite database file.
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+func detect(head []byte) bool {
+	return bytes.HasPrefix(head, sqliteHeader)
+}
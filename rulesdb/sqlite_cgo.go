@@ -0,0 +1,10 @@
+//go:build cgo
+
+package rulesdb
+
+import (
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// errNoSQLite is nil when the SQLite driver is linked in.
+var errNoSQLite error
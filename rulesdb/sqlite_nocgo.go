@@ -0,0 +1,9 @@
+//go:build !cgo
+
+package rulesdb
+
+import "errors"
+
+// errNoSQLite is returned when reading a rules.db from a binary built without
+// cgo, which the SQLite driver requires.
+var errNoSQLite = errors.New("reading a rules.db requires a build with cgo enabled (CGO_ENABLED=1)")
@@ -0,0 +1,122 @@
+package santactl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+type Rule struct {
+	RuleType   string `json:"rule_type"`
+	Policy     string `json:"policy"`
+	Identifier string `json:"identifier"`
+	CustomMsg  string `json:"custom_msg"`
+	CustomURL  string `json:"custom_url"`
+	Comment    string `json:"comment"`
+
+	// Tag is the Workshop tag a rule is scoped to. It is not part of the
+	// santactl format either, but is read back so that a failed tagged rule
+	// isn't imported again as a global rule.
+	Tag string `json:"tag,omitempty"`
+
+	// ErrorCode and ErrorMessage record why a rule failed to import. They are
+	// not part of the santactl format and are ignored when parsing, so a file
+	// of failed rules can be imported again once the problems are fixed.
+	ErrorCode    string `json:"error_code,omitempty"`
+	ErrorMessage string `json:"error_message,omitempty"`
+}
+
+type RulesFile struct {
+	Rules []Rule `json:"rules"`
+}
+
+// ParseRulesFromFile reads a santactl rules export and returns a slice of
+// rules. Rules with an unknown rule type or policy are skipped and reported in
+// a rulehelpers.RecordErrors error alongside the remaining rules.
+func ParseRulesFromFile(filePath string) ([]*apipb.Rule, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseRules(f, filePath)
+}
+
+// ParseRules is like ParseRulesFromFile but reads the export from r. name
+// identifies the export in record errors.
+func ParseRules(r io.Reader, name string) ([]*apipb.Rule, error) {
+	rules, _, err := parseRules(r, name)
+	return rules, err
+}
+
+func parseRules(r io.Reader, name string) ([]*apipb.Rule, rulehelpers.Locations, error) {
+	var rulesFile RulesFile
+	if err := json.NewDecoder(r).Decode(&rulesFile); err != nil {
+		return nil, nil, err
+	}
+	rules := make([]*apipb.Rule, 0, len(rulesFile.Rules))
+	locs := rulehelpers.Locations{}
+	var recordErrs rulehelpers.RecordErrors
+
+	for i, rule := range rulesFile.Rules {
+		location := fmt.Sprintf("%s: rules[%d]", name, i)
+		r, err := rule.ToWorkshopRule()
+		if err != nil {
+			recordErrs = append(recordErrs, &rulehelpers.RecordError{
+				Location:   location,
+				Identifier: rule.Identifier,
+				Err:        err,
+			})
+			continue
+		}
+		rules = append(rules, r)
+		locs[r] = location
+	}
+
+	return rules, locs, recordErrs.Err()
+}
+
+// ToWorkshopRule converts a rule in the santactl export format to Workshop
+// format.
+func (rule Rule) ToWorkshopRule() (*apipb.Rule, error) {
+	r, err := rulehelpers.NewRule(rule.RuleType, rule.Policy, rule.Identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	r.CustomMsg = rule.CustomMsg
+	r.CustomUrl = rule.CustomURL
+	r.Comment = rule.Comment
+	r.Tag = rule.Tag
+	return r, nil
+}
+
+// FromWorkshopRule converts a Workshop rule to the santactl export format.
+func FromWorkshopRule(rule *apipb.Rule) Rule {
+	return Rule{
+		RuleType:   rule.GetRuleType().String(),
+		Policy:     rule.GetPolicy().String(),
+		Identifier: rule.GetIdentifier(),
+		CustomMsg:  rule.GetCustomMsg(),
+		CustomURL:  rule.GetCustomUrl(),
+		Comment:    rule.GetComment(),
+		Tag:        rule.GetTag(),
+	}
+}
+
+// WriteRules writes rules to w in the santactl export format.
+func WriteRules(w io.Writer, rulesFile RulesFile) error {
+	if rulesFile.Rules == nil {
+		rulesFile.Rules = []Rule{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rulesFile)
+}
@@ -0,0 +1,106 @@
+package santactl_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/northpolesec/santa-rule-importer/santactl"
+	"github.com/northpolesec/santa-rule-importer/source"
+
+	"github.com/shoenig/test"
+	"github.com/shoenig/test/must"
+
+	syncpb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/sync"
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+func TestRuleTranslation(t *testing.T) {
+	// Test with a valid file
+	rules, err := santactl.ParseRulesFromFile("testdata/rules.json")
+	must.NoError(t, err)
+
+	must.Eq(t, 3, len(rules))
+
+	test.Eq(t, "EQHXZ8M8AV:com.google.Chrome.helper.renderer", rules[0].GetIdentifier())
+	test.Eq(t, syncpb.RuleType_SIGNINGID, rules[0].GetRuleType())
+	test.Eq(t, syncpb.Policy_ALLOWLIST, rules[0].GetPolicy())
+	test.Eq(t, "This is allowed", rules[0].GetCustomMsg())
+	test.Eq(t, "https://support.google.com/chrome/answer/95617",
+		rules[0].GetCustomUrl())
+	test.Eq(t, "/Applications/Google Chrome.app/Contents/Frameworks/Google Chrome Framework.framework/Versions/131.0.6778.86/Helpers/Google Chrome Helper (Renderer).app/Contents/MacOS/Google Chrome Helper (Renderer)", rules[0].GetComment())
+
+	test.Eq(t, "6c58905785bccb8a0854cca5a646c4ea6b20e522c9b61de842a759919df002e7", rules[1].GetIdentifier())
+	test.Eq(t, syncpb.RuleType_BINARY, rules[1].GetRuleType())
+	test.Eq(t, syncpb.Policy_ALLOWLIST, rules[1].GetPolicy())
+	test.Eq(t, "", rules[1].GetCustomMsg())
+	test.Eq(t, "", rules[1].GetCustomUrl())
+	test.Eq(t, "/Users/peterm/Library/Application Support/Code/User/globalStorage/llvm-vs-code-extensions.vscode-clangd/install/15.0.6/clangd_15.0.6/bin/clangd", rules[1].GetComment())
+
+	test.Eq(t, "platform:com.apple.osascript", rules[2].GetIdentifier())
+	test.Eq(t, syncpb.RuleType_SIGNINGID, rules[2].GetRuleType())
+	test.Eq(t, syncpb.Policy_BLOCKLIST, rules[2].GetPolicy())
+	test.Eq(t, "", rules[2].GetCustomMsg())
+	test.Eq(t, "", rules[2].GetCustomUrl())
+	test.Eq(t, "", rules[2].GetComment())
+}
+
+func TestInvalidRuleTranslation(t *testing.T) {
+	rules, err := santactl.ParseRulesFromFile("testdata/invalid.json")
+
+	var recordErrs rulehelpers.RecordErrors
+	must.ErrorAs(t, err, &recordErrs)
+	must.Eq(t, 1, len(recordErrs))
+	test.Eq(t, "testdata/invalid.json: rules[0]", recordErrs[0].Location)
+	test.ErrorContains(t, recordErrs[0], `unknown rule type: "SIGNING_ID"`)
+
+	must.Eq(t, 1, len(rules))
+	test.Eq(t, "platform:com.apple.osascript", rules[0].GetIdentifier())
+}
+
+func TestWriteRulesRoundTrip(t *testing.T) {
+	rule := santactl.FromWorkshopRule(&apipb.Rule{
+		RuleType:   syncpb.RuleType_SIGNINGID,
+		Policy:     syncpb.Policy_BLOCKLIST,
+		Identifier: "platform:com.apple.osascript",
+		CustomMsg:  "osascript is banned by policy",
+		CustomUrl:  "https://example.com/osascript",
+		Comment:    "Imported from Moroz",
+		Tag:        "kiosks",
+	})
+	rule.ErrorCode = "InvalidArgument"
+	rule.ErrorMessage = "bad rule"
+
+	var buf bytes.Buffer
+	must.NoError(t, santactl.WriteRules(&buf, santactl.RulesFile{Rules: []santactl.Rule{rule}}))
+	must.StrContains(t, buf.String(), `"error_code": "InvalidArgument"`)
+
+	path := filepath.Join(t.TempDir(), "failed.json")
+	must.NoError(t, os.WriteFile(path, buf.Bytes(), 0o600))
+
+	rules, err := santactl.ParseRulesFromFile(path)
+	must.NoError(t, err)
+	must.Eq(t, 1, len(rules))
+
+	test.Eq(t, syncpb.RuleType_SIGNINGID, rules[0].GetRuleType())
+	test.Eq(t, syncpb.Policy_BLOCKLIST, rules[0].GetPolicy())
+	test.Eq(t, "platform:com.apple.osascript", rules[0].GetIdentifier())
+	test.Eq(t, "osascript is banned by policy", rules[0].GetCustomMsg())
+	test.Eq(t, "https://example.com/osascript", rules[0].GetCustomUrl())
+	test.Eq(t, "Imported from Moroz", rules[0].GetComment())
+	test.Eq(t, "kiosks", rules[0].GetTag())
+}
+
+func TestDetect(t *testing.T) {
+	data, err := os.ReadFile("testdata/rules.json")
+	must.NoError(t, err)
+
+	reg, ok := source.Detect(data)
+	must.True(t, ok)
+	test.Eq(t, "santactl", reg.Name)
+
+	_, ok = source.Detect([]byte("identifier,type,policy\n"))
+	test.False(t, ok)
+}
@@ -0,0 +1,42 @@
+package santactl
+
+import (
+	"bytes"
+	"flag"
+	"io"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/northpolesec/santa-rule-importer/source"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+func init() {
+	source.Register(source.Registration{
+		Name:        "santactl",
+		Description: "santactl rule export JSON file",
+		Extensions:  []string{".json"},
+		Detect:      detect,
+		Driver:      driver{},
+	})
+}
+
+type driver struct{}
+
+func (driver) SetFlags(*flag.FlagSet) {}
+
+func (d driver) Open(path string) (source.Source, error) {
+	return source.OpenFile(d, path), nil
+}
+
+func (driver) Parse(r io.Reader, name string) ([]*apipb.Rule, rulehelpers.Locations, error) {
+	return parseRules(r, name)
+}
+
+// detect reports whether head is a JSON object with a rules key whose entries
+// have identifiers.
+func detect(head []byte) bool {
+	head = bytes.TrimSpace(head)
+	return bytes.HasPrefix(head, []byte("{")) && bytes.Contains(head, []byte(`"rules"`)) &&
+		bytes.Contains(head, []byte(`"identifier"`))
+}
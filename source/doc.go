@@ -0,0 +1,5 @@
+// Package source defines the interface implemented by rule sources and a
+// registry that lets the importer select a source by name or by file
+// extension. Sources register themselves from an init function, so a binary
+// can add its own sources by importing the package that registers them.
+package source
@@ -0,0 +1,384 @@
+package source
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// Source reads rules to import into Workshop. Records that could not be
+// converted are reported in a rulehelpers.RecordErrors error alongside the
+// rules that were.
+type Source interface {
+	Rules(ctx context.Context) ([]*apipb.Rule, error)
+}
+
+// Func adapts a function to the Source interface.
+type Func func(ctx context.Context) ([]*apipb.Rule, error)
+
+// Rules calls f(ctx).
+func (f Func) Rules(ctx context.Context) ([]*apipb.Rule, error) {
+	return f(ctx)
+}
+
+// Committer is implemented by sources that record their progress, such as
+// the rules already imported by an incremental import. Commit is called once
+// the rules have been imported successfully, and not after a dry run.
+type Committer interface {
+	Commit() error
+}
+
+// Driver creates a Source from its location and source-specific options.
+type Driver interface {
+	// SetFlags registers the options of the source on fs.
+	SetFlags(fs *flag.FlagSet)
+
+	// Open returns a Source reading from location, which is a file path, URL
+	// or server address depending on the source. It is called after the flags
+	// have been parsed.
+	Open(location string) (Source, error)
+}
+
+// Locator is implemented by sources that record where each rule was read,
+// such as the sources returned by OpenFile. Locations is valid once Rules has
+// returned.
+type Locator interface {
+	Locations() rulehelpers.Locations
+}
+
+// Parser is implemented by the drivers of file formats. It lets a file be read
+// from standard input, and be parsed once its format has been detected.
+type Parser interface {
+	// Parse reads rules from r, and the location of the record each was read
+	// from. name identifies the input in record errors and locations.
+	Parse(r io.Reader, name string) ([]*apipb.Rule, rulehelpers.Locations, error)
+}
+
+// Stdin is the location that reads a file format from standard input.
+const Stdin = "-"
+
+// OpenFile returns a Source that parses the file at path with p. If path is
+// Stdin, standard input is parsed instead.
+func OpenFile(p Parser, path string) Source {
+	return &fileSource{parser: p, path: path}
+}
+
+// fileSource parses a file, or a reader already opened on it.
+type fileSource struct {
+	parser Parser
+	path   string
+	r      io.Reader
+	closer io.Closer
+	locs   rulehelpers.Locations
+}
+
+func (s *fileSource) Rules(context.Context) ([]*apipb.Rule, error) {
+	r, name := s.r, s.path
+	if r == nil {
+		f, n, err := openFile(s.path)
+		if err != nil {
+			return nil, err
+		}
+		r, name, s.closer = f, n, f
+	}
+	defer s.closer.Close()
+
+	rules, locs, err := s.parser.Parse(r, name)
+	s.locs = locs
+	return rules, err
+}
+
+func (s *fileSource) Locations() rulehelpers.Locations {
+	return s.locs
+}
+
+func openFile(path string) (io.ReadCloser, string, error) {
+	if path == Stdin {
+		return io.NopCloser(os.Stdin), "stdin", nil
+	}
+	f, err := os.Open(path)
+	return f, path, err
+}
+
+// Registration describes a registered source.
+type Registration struct {
+	// Name selects the source with --source.
+	Name string
+
+	// Description is shown in the usage message.
+	Description string
+
+	// Extensions are the file extensions, including the leading dot, that
+	// select the source when no name is given. If the source also has a
+	// Detect function, the contents of the file are checked when it is opened,
+	// and a file recognized by another source is read by that source instead.
+	Extensions []string
+
+	// Detect, if set, reports whether head, the start of a file, is in the
+	// format of the source. The driver must implement Parser. It is used when
+	// the extension of a file doesn't select a source, or selects one whose
+	// Detect doesn't recognize the file.
+	Detect func(head []byte) bool
+
+	// LocationFlag, if set, names a flag that selects the source when it is
+	// given and whose value is used as the location instead of the first
+	// argument.
+	LocationFlag string
+
+	Driver Driver
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]*Registration{}
+)
+
+// Register makes a source available by name. It panics if the name is empty,
+// the driver is nil or the name is already registered.
+func Register(reg Registration) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if reg.Name == "" {
+		panic("source: Register called with an empty name")
+	}
+	if reg.Driver == nil {
+		panic("source: Register driver is nil for " + reg.Name)
+	}
+	if _, dup := registry[reg.Name]; dup {
+		panic("source: Register called twice for " + reg.Name)
+	}
+	registry[reg.Name] = &reg
+}
+
+// Lookup returns the source registered under name.
+func Lookup(name string) (*Registration, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	reg, ok := registry[name]
+	return reg, ok
+}
+
+// Registrations returns every registered source, sorted by name.
+func Registrations() []*Registration {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	regs := make([]*Registration, 0, len(registry))
+	for _, reg := range registry {
+		regs = append(regs, reg)
+	}
+	slices.SortFunc(regs, func(a, b *Registration) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return regs
+}
+
+// RegisterFlags registers the options of every source on fs.
+func RegisterFlags(fs *flag.FlagSet) {
+	for _, reg := range Registrations() {
+		reg.Driver.SetFlags(fs)
+	}
+}
+
+// Selection is the source chosen for an import.
+type Selection struct {
+	// Registration is nil if the source is a file whose format is detected
+	// when it is opened.
+	*Registration
+
+	// Location is passed to the driver's Open method.
+	Location string
+
+	// Args are the arguments left after the location was taken.
+	Args []string
+
+	// detect is set if Registration was selected by the file extension and
+	// the format is confirmed from the contents when the file is opened.
+	detect bool
+}
+
+// sniffLen is the amount of a file read to detect its format.
+const sniffLen = 64 << 10
+
+// Open opens the selected source. If the source is a file whose format wasn't
+// selected, the format is detected from its contents and Registration set. If
+// the format was selected by the file extension but another source recognizes
+// the contents, Registration is replaced with that source.
+func (s *Selection) Open() (Source, error) {
+	if s.Registration != nil && !s.detect {
+		return s.Driver.Open(s.Location)
+	}
+
+	f, name, err := openFile(s.Location)
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReaderSize(f, sniffLen)
+	head, err := r.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		f.Close()
+		return nil, err
+	}
+
+	reg := s.Registration
+	if reg == nil || !reg.Detect(head) {
+		if detected, ok := Detect(head); ok {
+			reg = detected
+		}
+	}
+	if reg == nil {
+		f.Close()
+		return nil, fmt.Errorf("can't detect the format of %s, pass --format or --source", name)
+	}
+	s.Registration = reg
+
+	return &fileSource{parser: reg.Driver.(Parser), path: name, r: r, closer: f}, nil
+}
+
+// Detect returns the source whose Detect function recognizes head, the start
+// of a file.
+func Detect(head []byte) (*Registration, bool) {
+	for _, reg := range Registrations() {
+		if reg.Detect != nil && reg.Detect(head) {
+			return reg, true
+		}
+	}
+	return nil, false
+}
+
+// ForFormat returns the source registered for a file format, given as a file
+// extension without the leading dot (e.g. "csv") or as a source name.
+func ForFormat(format string) (*Registration, bool) {
+	if reg, ok := Lookup(format); ok {
+		return reg, true
+	}
+	for _, reg := range Registrations() {
+		if slices.ContainsFunc(reg.Extensions, func(ext string) bool {
+			return strings.EqualFold(ext, "."+format)
+		}) {
+			return reg, true
+		}
+	}
+	return nil, false
+}
+
+// FlagSelected returns the first source whose LocationFlag was set on fs.
+func FlagSelected(fs *flag.FlagSet) (*Registration, bool) {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	for _, reg := range Registrations() {
+		if reg.LocationFlag != "" && set[reg.LocationFlag] {
+			return reg, true
+		}
+	}
+	return nil, false
+}
+
+// Select chooses a source after fs has been parsed. If name is set the source
+// registered under it is used. Otherwise the first source whose LocationFlag
+// was set on fs is used, falling back to the source registered for the file
+// extension of the first argument, which Open may replace with the source
+// that recognizes the file's contents. If no source matches, the first
+// argument is a file, or standard input, whose format is detected when it is
+// opened.
+func Select(fs *flag.FlagSet, name string, args []string) (Selection, error) {
+	set := map[string]string{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = f.Value.String() })
+
+	var reg *Registration
+	if name != "" {
+		var ok bool
+		if reg, ok = Lookup(name); !ok {
+			return Selection{}, fmt.Errorf("unknown source %q", name)
+		}
+	} else {
+		reg, _ = FlagSelected(fs)
+	}
+
+	if reg != nil {
+		if loc, ok := set[reg.LocationFlag]; ok && reg.LocationFlag != "" {
+			return Selection{Registration: reg, Location: loc, Args: args}, nil
+		}
+	}
+
+	if len(args) == 0 {
+		return Selection{}, fmt.Errorf("no source location given")
+	}
+
+	if reg == nil && args[0] != Stdin {
+		ext := filepath.Ext(args[0])
+		for _, r := range Registrations() {
+			if slices.ContainsFunc(r.Extensions, func(e string) bool { return strings.EqualFold(e, ext) }) {
+				// Other formats share the extension, such as osquery
+				// results saved as .json or .csv, so the contents decide.
+				return Selection{Registration: r, Location: args[0], Args: args[1:], detect: r.Detect != nil}, nil
+			}
+		}
+	}
+
+	return Selection{Registration: reg, Location: args[0], Args: args[1:]}, nil
+}
+
+// IdentifierValidation is how Read handles identifiers that don't have the
+// expected shape for their rule type.
+type IdentifierValidation string
+
+const (
+	// RejectIdentifiers reports malformed identifiers as invalid records.
+	RejectIdentifiers IdentifierValidation = "reject"
+
+	// WarnIdentifiers keeps the rules as read and reports their malformed
+	// identifiers as warnings.
+	WarnIdentifiers IdentifierValidation = "warn"
+
+	// SkipIdentifiers doesn't check identifiers.
+	SkipIdentifiers IdentifierValidation = "off"
+)
+
+// ParseIdentifierValidation returns the IdentifierValidation named v.
+func ParseIdentifierValidation(v string) (IdentifierValidation, error) {
+	switch iv := IdentifierValidation(v); iv {
+	case RejectIdentifiers, WarnIdentifiers, SkipIdentifiers:
+		return iv, nil
+	}
+	return "", fmt.Errorf("identifier validation must be one of reject, warn or off, not %q", v)
+}
+
+// Read reads the rules of src and checks their identifiers. It returns the
+// valid rules, the invalid records, and with WarnIdentifiers the rules whose
+// identifiers are malformed. Identifiers are checked before the rules are
+// filtered so that they are located by the source's own records, if src is a
+// Locator. An error is only returned if the source could not be read at all.
+func Read(ctx context.Context, src Source, validation IdentifierValidation) (rules []*apipb.Rule, invalid, warnings rulehelpers.RecordErrors, err error) {
+	rules, err = src.Rules(ctx)
+	if err != nil && !errors.As(err, &invalid) {
+		return nil, nil, nil, err
+	}
+	if validation == SkipIdentifiers {
+		return rules, invalid, nil, nil
+	}
+
+	var locs rulehelpers.Locations
+	if locator, ok := src.(Locator); ok {
+		locs = locator.Locations()
+	}
+	valid, malformed := rulehelpers.ValidateIdentifiers(rules, locs)
+	if validation == WarnIdentifiers {
+		return rules, invalid, malformed, nil
+	}
+	return valid, append(invalid, malformed...), nil, nil
+}
@@ -0,0 +1,281 @@
+package source_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shoenig/test"
+	"github.com/shoenig/test/must"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/northpolesec/santa-rule-importer/source"
+
+	syncpb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/sync"
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// fakeDriver returns a single rule whose identifier is the location it was
+// opened with.
+type fakeDriver struct {
+	flagName string
+	value    string
+}
+
+func (d *fakeDriver) SetFlags(fs *flag.FlagSet) {
+	if d.flagName != "" {
+		fs.StringVar(&d.value, d.flagName, "", "")
+	}
+}
+
+func (d *fakeDriver) Open(location string) (source.Source, error) {
+	return source.Func(func(context.Context) ([]*apipb.Rule, error) {
+		return []*apipb.Rule{{Identifier: location}}, nil
+	}), nil
+}
+
+// lineParser reads one rule identifier per line, after a "TEST" header line.
+type lineParser struct{}
+
+func (lineParser) SetFlags(*flag.FlagSet) {}
+
+func (p lineParser) Open(path string) (source.Source, error) {
+	return source.OpenFile(p, path), nil
+}
+
+func (lineParser) Parse(r io.Reader, name string) ([]*apipb.Rule, rulehelpers.Locations, error) {
+	var rules []*apipb.Rule
+	locs := rulehelpers.Locations{}
+	scanner := bufio.NewScanner(r)
+	scanner.Scan()
+	for line := 2; scanner.Scan(); line++ {
+		rule := &apipb.Rule{Identifier: scanner.Text(), Comment: name}
+		rules = append(rules, rule)
+		locs[rule] = fmt.Sprintf("%s:%d", name, line)
+	}
+	return rules, locs, scanner.Err()
+}
+
+func init() {
+	source.Register(source.Registration{
+		Name:   "test-lines",
+		Detect: func(head []byte) bool { return bytes.HasPrefix(head, []byte("TEST\n")) },
+		Driver: lineParser{},
+	})
+	source.Register(source.Registration{
+		Name:       "test-ext-lines",
+		Extensions: []string{".lines"},
+		Detect:     func(head []byte) bool { return bytes.HasPrefix(head, []byte("LINES\n")) },
+		Driver:     lineParser{},
+	})
+	source.Register(source.Registration{
+		Name:       "test-file",
+		Extensions: []string{".testfile"},
+		Driver:     &fakeDriver{},
+	})
+	source.Register(source.Registration{
+		Name:         "test-server",
+		LocationFlag: "test-server-url",
+		Driver:       &fakeDriver{flagName: "test-server-url"},
+	})
+}
+
+func parse(t *testing.T, args ...string) *flag.FlagSet {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	source.RegisterFlags(fs)
+	must.NoError(t, fs.Parse(args))
+	return fs
+}
+
+func TestSelectByExtension(t *testing.T) {
+	fs := parse(t, "rules.testfile", "nps.workshop.cloud")
+
+	sel, err := source.Select(fs, "", fs.Args())
+	must.NoError(t, err)
+	test.Eq(t, "test-file", sel.Name)
+	test.Eq(t, "rules.testfile", sel.Location)
+	test.Eq(t, []string{"nps.workshop.cloud"}, sel.Args)
+
+	src, err := sel.Open()
+	must.NoError(t, err)
+	rules, err := src.Rules(context.Background())
+	must.NoError(t, err)
+	must.Eq(t, 1, len(rules))
+	test.Eq(t, "rules.testfile", rules[0].GetIdentifier())
+}
+
+func TestSelectByName(t *testing.T) {
+	fs := parse(t, "rules.txt", "nps.workshop.cloud")
+
+	sel, err := source.Select(fs, "test-file", fs.Args())
+	must.NoError(t, err)
+	test.Eq(t, "test-file", sel.Name)
+	test.Eq(t, "rules.txt", sel.Location)
+
+	_, err = source.Select(fs, "missing", fs.Args())
+	test.ErrorContains(t, err, `unknown source "missing"`)
+}
+
+func TestSelectByLocationFlag(t *testing.T) {
+	fs := parse(t, "--test-server-url", "example.com", "nps.workshop.cloud")
+
+	reg, ok := source.FlagSelected(fs)
+	must.True(t, ok)
+	test.Eq(t, "test-server", reg.Name)
+
+	sel, err := source.Select(fs, "", fs.Args())
+	must.NoError(t, err)
+	test.Eq(t, "test-server", sel.Name)
+	test.Eq(t, "example.com", sel.Location)
+	test.Eq(t, []string{"nps.workshop.cloud"}, sel.Args)
+
+	// Selected by name, the location is taken from the arguments.
+	fs = parse(t, "example.com", "nps.workshop.cloud")
+	_, ok = source.FlagSelected(fs)
+	test.False(t, ok)
+	sel, err = source.Select(fs, "test-server", fs.Args())
+	must.NoError(t, err)
+	test.Eq(t, "example.com", sel.Location)
+	test.Eq(t, []string{"nps.workshop.cloud"}, sel.Args)
+}
+
+func TestSelectExtensionIgnoresCase(t *testing.T) {
+	fs := parse(t, "RULES.TESTFILE", "nps.workshop.cloud")
+
+	sel, err := source.Select(fs, "", fs.Args())
+	must.NoError(t, err)
+	test.Eq(t, "test-file", sel.Name)
+}
+
+func TestSelectDetectsFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.txt")
+	must.NoError(t, os.WriteFile(path, []byte("TEST\nEQHXZ8M8AV\nBJ4HAAB9B3\n"), 0o644))
+
+	fs := parse(t, path, "nps.workshop.cloud")
+	sel, err := source.Select(fs, "", fs.Args())
+	must.NoError(t, err)
+	test.Nil(t, sel.Registration)
+
+	src, err := sel.Open()
+	must.NoError(t, err)
+	test.Eq(t, "test-lines", sel.Name)
+
+	rules, err := src.Rules(context.Background())
+	must.NoError(t, err)
+	must.Eq(t, 2, len(rules))
+	test.Eq(t, "EQHXZ8M8AV", rules[0].GetIdentifier())
+	test.Eq(t, path, rules[0].GetComment())
+
+	locator, ok := src.(source.Locator)
+	must.True(t, ok)
+	test.Eq(t, path+":3", locator.Locations()[rules[1]])
+}
+
+func TestSelectExtensionDetectsFormat(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		content string
+		want    string
+	}{
+		{"LINES\nEQHXZ8M8AV\n", "test-ext-lines"},
+		{"TEST\nEQHXZ8M8AV\n", "test-lines"},
+		// Contents no source recognizes are left to the extension's source.
+		{"other\nEQHXZ8M8AV\n", "test-ext-lines"},
+	} {
+		path := filepath.Join(dir, "rules.lines")
+		must.NoError(t, os.WriteFile(path, []byte(tc.content), 0o644))
+
+		fs := parse(t, path, "nps.workshop.cloud")
+		sel, err := source.Select(fs, "", fs.Args())
+		must.NoError(t, err)
+		test.Eq(t, "test-ext-lines", sel.Name)
+
+		src, err := sel.Open()
+		must.NoError(t, err)
+		test.Eq(t, tc.want, sel.Name)
+
+		rules, err := src.Rules(context.Background())
+		must.NoError(t, err)
+		must.Eq(t, 1, len(rules))
+		test.Eq(t, "EQHXZ8M8AV", rules[0].GetIdentifier())
+	}
+}
+
+func TestSelectUnknownFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.txt")
+	must.NoError(t, os.WriteFile(path, []byte("not rules\n"), 0o644))
+
+	fs := parse(t, path, "nps.workshop.cloud")
+	sel, err := source.Select(fs, "", fs.Args())
+	must.NoError(t, err)
+
+	_, err = sel.Open()
+	test.ErrorContains(t, err, "can't detect the format")
+
+	_, err = source.Select(fs, "", nil)
+	test.ErrorContains(t, err, "no source location")
+}
+
+func TestForFormat(t *testing.T) {
+	reg, ok := source.ForFormat("TESTFILE")
+	must.True(t, ok)
+	test.Eq(t, "test-file", reg.Name)
+
+	reg, ok = source.ForFormat("test-lines")
+	must.True(t, ok)
+	test.Eq(t, "test-lines", reg.Name)
+
+	_, ok = source.ForFormat("xml")
+	test.False(t, ok)
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	defer func() {
+		test.NotNil(t, recover())
+	}()
+	source.Register(source.Registration{Name: "test-file", Driver: &fakeDriver{}})
+}
+
+func TestRead(t *testing.T) {
+	src := source.Func(func(context.Context) ([]*apipb.Rule, error) {
+		return []*apipb.Rule{
+			{RuleType: syncpb.RuleType_TEAMID, Policy: syncpb.Policy_BLOCKLIST, Identifier: "eqhxz8m8av"},
+			{RuleType: syncpb.RuleType_TEAMID, Policy: syncpb.Policy_BLOCKLIST, Identifier: "not a team"},
+		}, rulehelpers.RecordErrors{
+			{Location: "rules.csv:4", Identifier: "BJ4HAAB9B3", Err: errors.New("unknown policy")},
+		}
+	})
+
+	rules, invalid, warnings, err := source.Read(context.Background(), src, source.RejectIdentifiers)
+	must.NoError(t, err)
+	must.Eq(t, 1, len(rules))
+	test.Eq(t, "EQHXZ8M8AV", rules[0].GetIdentifier())
+	must.Eq(t, 2, len(invalid))
+	test.Eq(t, "rules.csv:4", invalid[0].Location)
+	test.Eq(t, "rule 1", invalid[1].Location)
+	test.Eq(t, 0, len(warnings))
+
+	rules, invalid, warnings, err = source.Read(context.Background(), src, source.WarnIdentifiers)
+	must.NoError(t, err)
+	test.Eq(t, 2, len(rules))
+	test.Eq(t, 1, len(invalid))
+	test.Eq(t, 1, len(warnings))
+
+	rules, invalid, warnings, err = source.Read(context.Background(), src, source.SkipIdentifiers)
+	must.NoError(t, err)
+	test.Eq(t, 2, len(rules))
+	test.Eq(t, "eqhxz8m8av", rules[0].GetIdentifier())
+	test.Eq(t, 1, len(invalid))
+	test.Eq(t, 0, len(warnings))
+
+	_, err = source.ParseIdentifierValidation("strict")
+	test.Error(t, err)
+}
@@ -0,0 +1,3 @@
+// Package upvote reads rules exported from Upvote's Datastore or BigQuery
+// tables and converts them to Workshop format.
+package upvote
@@ -0,0 +1,49 @@
+package upvote
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"io"
+	"slices"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/northpolesec/santa-rule-importer/source"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+func init() {
+	source.Register(source.Registration{
+		Name:        "upvote",
+		Description: "Upvote rules export (JSON or CSV)",
+		Detect:      detect,
+		Driver:      driver{},
+	})
+}
+
+type driver struct{}
+
+func (driver) SetFlags(*flag.FlagSet) {}
+
+func (d driver) Open(path string) (source.Source, error) {
+	return source.OpenFile(d, path), nil
+}
+
+func (driver) Parse(r io.Reader, name string) ([]*apipb.Rule, rulehelpers.Locations, error) {
+	return parseRules(r, name)
+}
+
+// detect reports whether head is a JSON export whose rules refer to
+// blockables, or a CSV export with a blockable_id column. Upvote exports use
+// the same extensions as Rudolph and santactl files.
+func detect(head []byte) bool {
+	trimmed := bytes.TrimSpace(head)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return bytes.Contains(trimmed, []byte(`"`+ColBlockableID+`"`))
+	}
+
+	line, _, _ := bytes.Cut(head, []byte("\n"))
+	header, err := csv.NewReader(bytes.NewReader(line)).Read()
+	return err == nil && slices.Contains(header, ColBlockableID) && slices.Contains(header, ColPolicy)
+}
@@ -0,0 +1,284 @@
+package upvote
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/northpolesec/santa-rule-importer/internal/textutil"
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// Rule is an Upvote rule entity. BlockableID is the SHA-256 of the binary or
+// the fingerprint of the certificate the rule applies to. Rules with a HostID
+// are local to that host.
+type Rule struct {
+	BlockableID string `json:"blockable_id"`
+	RuleType    string `json:"rule_type"`
+	Policy      string `json:"policy"`
+	InEffect    *bool  `json:"in_effect,omitempty"`
+	HostID      string `json:"host_id,omitempty"`
+	Notes       string `json:"notes,omitempty"`
+}
+
+// Blockable is an Upvote blockable entity, used to describe the rules that
+// refer to it.
+type Blockable struct {
+	ID          string `json:"id"`
+	FileName    string `json:"file_name,omitempty"`
+	Publisher   string `json:"publisher,omitempty"`
+	ProductName string `json:"product_name,omitempty"`
+	CommonName  string `json:"common_name,omitempty"`
+}
+
+// Description returns a short description of the blockable for use as a rule
+// comment.
+func (b Blockable) Description() string {
+	var name string
+	switch {
+	case b.FileName != "":
+		name = b.FileName
+	case b.ProductName != "":
+		name = b.ProductName
+	default:
+		name = b.CommonName
+	}
+	if b.Publisher != "" && name != "" {
+		return fmt.Sprintf("%s (%s)", name, b.Publisher)
+	}
+	return name + b.Publisher
+}
+
+// Export is a JSON export of Upvote rules and, optionally, the blockables
+// they refer to.
+type Export struct {
+	Rules      []Rule      `json:"rules"`
+	Blockables []Blockable `json:"blockables,omitempty"`
+}
+
+// policies maps Upvote policies to the names accepted by rulehelpers.
+var policies = map[string]string{
+	"WHITELIST":        "ALLOWLIST",
+	"BLACKLIST":        "BLOCKLIST",
+	"SILENT_BLACKLIST": "SILENT_BLOCKLIST",
+}
+
+// Errors for Upvote rules that have no Workshop equivalent.
+var (
+	ErrRemove    = errors.New("REMOVE rules only delete rules from hosts and have no Workshop equivalent")
+	ErrInstaller = errors.New("installer policies have no Workshop equivalent")
+	ErrPackage   = errors.New("package rules have no Workshop equivalent")
+)
+
+// ToWorkshopRule converts an Upvote rule to Workshop format. Local rules are
+// scoped to their host with a "host:" tag. If blockable is not nil its
+// description is used as the comment when the rule has no notes.
+func (rule Rule) ToWorkshopRule(blockable *Blockable) (*apipb.Rule, error) {
+	policy := strings.ToUpper(strings.TrimSpace(rule.Policy))
+	switch policy {
+	case "REMOVE":
+		return nil, ErrRemove
+	case "FORCE_INSTALLER", "FORCE_NOT_INSTALLER":
+		return nil, ErrInstaller
+	}
+	if p, ok := policies[policy]; ok {
+		policy = p
+	}
+
+	ruleType := strings.ToUpper(strings.TrimSpace(rule.RuleType))
+	if ruleType == "PACKAGE" {
+		return nil, ErrPackage
+	}
+
+	r, err := rulehelpers.NewRule(ruleType, policy, rule.BlockableID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Comment = rule.Notes
+	if r.Comment == "" && blockable != nil {
+		r.Comment = blockable.Description()
+	}
+	if rule.HostID != "" {
+		r.Tag = "host:" + rule.HostID
+	}
+	return r, nil
+}
+
+// ParseRulesFromFile reads an Upvote export, in JSON or CSV format, and
+// returns a slice of rules. Rules that are no longer in effect are skipped.
+// Rules that can't be represented in Workshop are skipped and reported in a
+// rulehelpers.RecordErrors error alongside the remaining rules.
+func ParseRulesFromFile(filePath string) ([]*apipb.Rule, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseRules(f, filePath)
+}
+
+// ParseRules is like ParseRulesFromFile but reads the export from r. name
+// identifies the export in record errors.
+func ParseRules(r io.Reader, name string) ([]*apipb.Rule, error) {
+	rules, _, err := parseRules(r, name)
+	return rules, err
+}
+
+func parseRules(r io.Reader, name string) ([]*apipb.Rule, rulehelpers.Locations, error) {
+	br := bufio.NewReader(r)
+	first, err := textutil.FirstByte(br)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var records []record
+	var recordErrs rulehelpers.RecordErrors
+	blockables := map[string]*Blockable{}
+
+	if first == '{' {
+		var export Export
+		if err := json.NewDecoder(br).Decode(&export); err != nil {
+			return nil, nil, err
+		}
+		for i := range export.Blockables {
+			blockables[export.Blockables[i].ID] = &export.Blockables[i]
+		}
+		for i, rule := range export.Rules {
+			records = append(records, record{rule, fmt.Sprintf("%s: rules[%d]", name, i)})
+		}
+	} else {
+		if records, recordErrs, err = readCSV(br, name, blockables); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	rules := []*apipb.Rule{}
+	locs := rulehelpers.Locations{}
+
+	for _, rec := range records {
+		if rec.InEffect != nil && !*rec.InEffect {
+			continue
+		}
+
+		r, err := rec.ToWorkshopRule(blockables[rec.BlockableID])
+		if err != nil {
+			recordErrs = append(recordErrs, &rulehelpers.RecordError{
+				Location:   rec.location,
+				Identifier: rec.BlockableID,
+				Err:        err,
+			})
+			continue
+		}
+		rules = append(rules, r)
+		locs[r] = rec.location
+	}
+
+	return rules, locs, recordErrs.Err()
+}
+
+// record is a rule and its location in the export.
+type record struct {
+	Rule
+	location string
+}
+
+// Upvote CSV column names. Exports joined with the blockables table may also
+// have the blockable columns, which are added to blockables.
+const (
+	ColBlockableID = "blockable_id"
+	ColRuleType    = "rule_type"
+	ColPolicy      = "policy"
+	ColInEffect    = "in_effect"
+	ColHostID      = "host_id"
+	ColNotes       = "notes"
+	ColFileName    = "file_name"
+	ColPublisher   = "publisher"
+	ColProductName = "product_name"
+	ColCommonName  = "common_name"
+)
+
+// readCSV reads the rows of a CSV export and the blockables described by
+// them. Rows with an invalid in_effect value are reported as record errors.
+func readCSV(r io.Reader, name string, blockables map[string]*Blockable) ([]record, rulehelpers.RecordErrors, error) {
+	reader := csv.NewReader(r)
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading CSV header: %w", err)
+	}
+
+	colIndices := make(map[string]int)
+	for i, col := range header {
+		colIndices[strings.TrimSpace(col)] = i
+	}
+	for _, col := range []string{ColBlockableID, ColRuleType, ColPolicy} {
+		if _, ok := colIndices[col]; !ok {
+			return nil, nil, fmt.Errorf("missing required column: %s", col)
+		}
+	}
+
+	var records []record
+	var recordErrs rulehelpers.RecordErrors
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		location := fmt.Sprintf("%s:%d", name, line)
+
+		field := func(col string) string {
+			if idx, ok := colIndices[col]; ok && idx < len(row) {
+				return row[idx]
+			}
+			return ""
+		}
+
+		rule := Rule{
+			BlockableID: field(ColBlockableID),
+			RuleType:    field(ColRuleType),
+			Policy:      field(ColPolicy),
+			HostID:      field(ColHostID),
+			Notes:       field(ColNotes),
+		}
+		if v := field(ColInEffect); v != "" {
+			inEffect, err := strconv.ParseBool(v)
+			if err != nil {
+				recordErrs = append(recordErrs, &rulehelpers.RecordError{
+					Location:   location,
+					Identifier: rule.BlockableID,
+					Err:        fmt.Errorf("invalid %s value %q", ColInEffect, v),
+				})
+				continue
+			}
+			rule.InEffect = &inEffect
+		}
+
+		blockable := Blockable{
+			ID:          rule.BlockableID,
+			FileName:    field(ColFileName),
+			Publisher:   field(ColPublisher),
+			ProductName: field(ColProductName),
+			CommonName:  field(ColCommonName),
+		}
+		if blockable != (Blockable{ID: rule.BlockableID}) {
+			blockables[blockable.ID] = &blockable
+		}
+
+		records = append(records, record{rule, location})
+	}
+	return records, recordErrs, nil
+}
@@ -0,0 +1,85 @@
+package upvote_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/shoenig/test"
+	"github.com/shoenig/test/must"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/northpolesec/santa-rule-importer/source"
+	"github.com/northpolesec/santa-rule-importer/upvote"
+
+	syncpb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/sync"
+)
+
+func TestParseRulesFromFileJSON(t *testing.T) {
+	rules, err := upvote.ParseRulesFromFile("testdata/upvote.json")
+
+	var recordErrs rulehelpers.RecordErrors
+	must.ErrorAs(t, err, &recordErrs)
+	must.Eq(t, 3, len(recordErrs))
+	test.Eq(t, "testdata/upvote.json: rules[4]", recordErrs[0].Location)
+	test.ErrorIs(t, recordErrs[0], upvote.ErrInstaller)
+	test.ErrorIs(t, recordErrs[1], upvote.ErrPackage)
+	test.ErrorIs(t, recordErrs[2], upvote.ErrRemove)
+
+	// The rule that is no longer in effect is skipped.
+	must.Eq(t, 3, len(rules))
+
+	test.Eq(t, "6c58905785bccb8a0854cca5a646c4ea6b20e522c9b61de842a759919df002e7", rules[0].GetIdentifier())
+	test.Eq(t, syncpb.RuleType_BINARY, rules[0].GetRuleType())
+	test.Eq(t, syncpb.Policy_ALLOWLIST, rules[0].GetPolicy())
+	test.Eq(t, "clangd (LLVM)", rules[0].GetComment())
+	test.Eq(t, "", rules[0].GetTag())
+
+	test.Eq(t, syncpb.RuleType_CERTIFICATE, rules[1].GetRuleType())
+	test.Eq(t, syncpb.Policy_BLOCKLIST, rules[1].GetPolicy())
+	test.Eq(t, "Blocked after review", rules[1].GetComment())
+
+	test.Eq(t, "host:A1B2C3D4-E5F6-7890-ABCD-EF1234567890", rules[2].GetTag())
+}
+
+func TestParseRulesFromFileCSV(t *testing.T) {
+	rules, err := upvote.ParseRulesFromFile("testdata/upvote.csv")
+
+	var recordErrs rulehelpers.RecordErrors
+	must.ErrorAs(t, err, &recordErrs)
+	must.Eq(t, 1, len(recordErrs))
+	test.Eq(t, "testdata/upvote.csv:5", recordErrs[0].Location)
+	test.ErrorIs(t, recordErrs[0], upvote.ErrInstaller)
+
+	must.Eq(t, 2, len(rules))
+	test.Eq(t, "clangd (LLVM)", rules[0].GetComment())
+	test.Eq(t, syncpb.Policy_SILENT_BLOCKLIST, rules[1].GetPolicy())
+	test.Eq(t, "Blocked quietly", rules[1].GetComment())
+}
+
+func TestParseRulesInvalidInEffect(t *testing.T) {
+	csv := "blockable_id,rule_type,policy,in_effect\n" +
+		"6c58905785bccb8a0854cca5a646c4ea6b20e522c9b61de842a759919df002e7,BINARY,WHITELIST,maybe\n" +
+		"d84db96af8c2e60ac4c851a21ec460f6f84e0235beb17d24a78712b9b021ed57,CERTIFICATE,BLACKLIST,true\n"
+	rules, err := upvote.ParseRules(strings.NewReader(csv), "upvote.csv")
+
+	var recordErrs rulehelpers.RecordErrors
+	must.ErrorAs(t, err, &recordErrs)
+	must.Eq(t, 1, len(recordErrs))
+	test.Eq(t, "upvote.csv:2", recordErrs[0].Location)
+	test.ErrorContains(t, recordErrs[0], `invalid in_effect value "maybe"`)
+
+	must.Eq(t, 1, len(rules))
+	test.Eq(t, syncpb.RuleType_CERTIFICATE, rules[0].GetRuleType())
+}
+
+func TestDetect(t *testing.T) {
+	for _, path := range []string{"testdata/upvote.json", "testdata/upvote.csv"} {
+		data, err := os.ReadFile(path)
+		must.NoError(t, err)
+
+		reg, ok := source.Detect(data)
+		must.True(t, ok)
+		test.Eq(t, "upvote", reg.Name)
+	}
+}
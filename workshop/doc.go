@@ -0,0 +1,3 @@
+// Package workshop provides helpers for reading rules from a Workshop instance
+// using the Workshop API.
+package workshop
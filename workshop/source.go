@@ -0,0 +1,48 @@
+package workshop
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/northpolesec/santa-rule-importer/source"
+
+	svcpb "buf.build/gen/go/northpolesec/workshop-api/grpc/go/workshop/v1/workshopv1grpc"
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+func init() {
+	source.Register(source.Registration{
+		Name:         "workshop",
+		Description:  "another Workshop server",
+		LocationFlag: "workshop-source",
+		Driver:       &driver{},
+	})
+}
+
+type driver struct {
+	server      string
+	useInsecure bool
+}
+
+func (d *driver) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&d.server, "workshop-source", "", "Copy rules from this Workshop server (e.g., staging.workshop.cloud)")
+	fs.BoolVar(&d.useInsecure, "workshop-source-insecure", false, "Use insecure connection to the --workshop-source server")
+}
+
+func (d *driver) Open(server string) (source.Source, error) {
+	apiKey := os.Getenv("WORKSHOP_SOURCE_API_KEY")
+	if apiKey == "" {
+		return nil, errors.New("please set WORKSHOP_SOURCE_API_KEY environment variable for Workshop imports")
+	}
+
+	return source.Func(func(ctx context.Context) ([]*apipb.Rule, error) {
+		conn, err := Dial(server, apiKey, d.useInsecure)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		return GetRules(ctx, svcpb.NewWorkshopServiceClient(conn))
+	}), nil
+}
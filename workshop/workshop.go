@@ -0,0 +1,97 @@
+package workshop
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// DefaultPageSize is the number of rules requested per ListRules call.
+const DefaultPageSize = 500
+
+// Dial connects to the Workshop API at server, authenticating every request
+// with apiKey. If useInsecure is set the connection is made without TLS.
+func Dial(server, apiKey string, useInsecure bool) (*grpc.ClientConn, error) {
+	opts := []grpc.DialOption{
+		grpc.WithPerRPCCredentials(apiKeyAuthorizer(apiKey)),
+	}
+
+	if useInsecure {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	} else {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{})))
+	}
+
+	return grpc.NewClient(fmt.Sprintf("dns:%s", server), opts...)
+}
+
+// apiKeyAuthorizer is a custom authorizer that adds the API key to the request
+// metadata.
+type apiKeyAuthorizer string
+
+func (k apiKeyAuthorizer) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"Authorization": string(k)}, nil
+}
+func (k apiKeyAuthorizer) RequireTransportSecurity() bool {
+	return false
+}
+
+// RuleClient is the subset of the Workshop API used to manage rules. It is
+// satisfied by the generated WorkshopServiceClient.
+type RuleClient interface {
+	ListRules(ctx context.Context, in *apipb.ListRulesRequest, opts ...grpc.CallOption) (*apipb.ListRulesResponse, error)
+	CreateRule(ctx context.Context, in *apipb.CreateRuleRequest, opts ...grpc.CallOption) (*apipb.CreateRuleResponse, error)
+	DeleteRule(ctx context.Context, in *apipb.DeleteRuleRequest, opts ...grpc.CallOption) (*apipb.DeleteRuleResponse, error)
+}
+
+// ListRules retrieves every rule from the Workshop instance, following
+// pagination until a short page is returned.
+func ListRules(ctx context.Context, client RuleClient) ([]*apipb.Rule, error) {
+	var rules []*apipb.Rule
+
+	for page := int32(0); ; page++ {
+		resp, err := client.ListRules(ctx, &apipb.ListRulesRequest{
+			PageSize: proto.Int32(DefaultPageSize),
+			Page:     proto.Int32(page),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list rules (page %d): %w", page, err)
+		}
+
+		rules = append(rules, resp.GetRules()...)
+
+		if len(resp.GetRules()) < DefaultPageSize {
+			break
+		}
+	}
+
+	return rules, nil
+}
+
+// GetRules retrieves every rule from a Workshop instance so that they can be
+// created in another instance. Fields assigned by the server, such as the rule
+// ID and timestamps, are cleared.
+func GetRules(ctx context.Context, client RuleClient) ([]*apipb.Rule, error) {
+	existing, err := ListRules(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]*apipb.Rule, 0, len(existing))
+	for _, rule := range existing {
+		rule = proto.Clone(rule).(*apipb.Rule)
+		rule.RuleId = ""
+		rule.CreatedAt = nil
+		rule.UpdatedAt = nil
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,92 @@
+package workshop_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/shoenig/test"
+	"github.com/shoenig/test/must"
+	"google.golang.org/grpc"
+
+	"github.com/northpolesec/santa-rule-importer/workshop"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	syncpb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/sync"
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// pagedClient serves a fixed number of rules in pages.
+type pagedClient struct {
+	workshop.RuleClient
+	total int
+	pages []int32
+}
+
+func (p *pagedClient) ListRules(ctx context.Context, in *apipb.ListRulesRequest, opts ...grpc.CallOption) (*apipb.ListRulesResponse, error) {
+	p.pages = append(p.pages, in.GetPage())
+
+	resp := &apipb.ListRulesResponse{}
+	start := int(in.GetPage() * in.GetPageSize())
+	for i := start; i < p.total && i < start+int(in.GetPageSize()); i++ {
+		resp.Rules = append(resp.Rules, &apipb.Rule{Identifier: fmt.Sprintf("rule%d", i)})
+	}
+	return resp, nil
+}
+
+func TestListRulesPaginated(t *testing.T) {
+	client := &pagedClient{total: workshop.DefaultPageSize + 3}
+
+	rules, err := workshop.ListRules(context.Background(), client)
+	must.NoError(t, err)
+
+	test.Eq(t, workshop.DefaultPageSize+3, len(rules))
+	test.Eq(t, []int32{0, 1}, client.pages)
+	test.Eq(t, "rule0", rules[0].GetIdentifier())
+	test.Eq(t, fmt.Sprintf("rule%d", workshop.DefaultPageSize+2), rules[len(rules)-1].GetIdentifier())
+}
+
+// staticClient serves a fixed set of rules from a single page.
+type staticClient struct {
+	workshop.RuleClient
+	rules []*apipb.Rule
+}
+
+func (s *staticClient) ListRules(ctx context.Context, in *apipb.ListRulesRequest, opts ...grpc.CallOption) (*apipb.ListRulesResponse, error) {
+	return &apipb.ListRulesResponse{Rules: s.rules}, nil
+}
+
+func TestGetRules(t *testing.T) {
+	client := &staticClient{rules: []*apipb.Rule{
+		{
+			RuleId:     "r1",
+			RuleType:   syncpb.RuleType_TEAMID,
+			Policy:     syncpb.Policy_ALLOWLIST,
+			Identifier: "EQHXZ8M8AV",
+			Comment:    "Google",
+			Tag:        "engineering",
+			CreatedAt:  timestamppb.Now(),
+			UpdatedAt:  timestamppb.Now(),
+		},
+		{
+			RuleId:     "r2",
+			RuleType:   syncpb.RuleType_SIGNINGID,
+			Policy:     syncpb.Policy_BLOCKLIST,
+			Identifier: "platform:com.apple.osascript",
+		},
+	}}
+
+	rules, err := workshop.GetRules(context.Background(), client)
+	must.NoError(t, err)
+	must.Eq(t, 2, len(rules))
+
+	test.Eq(t, "", rules[0].GetRuleId())
+	test.Nil(t, rules[0].GetCreatedAt())
+	test.Nil(t, rules[0].GetUpdatedAt())
+	test.Eq(t, "EQHXZ8M8AV", rules[0].GetIdentifier())
+	test.Eq(t, "Google", rules[0].GetComment())
+	test.Eq(t, "engineering", rules[0].GetTag())
+
+	// The source rules are left untouched
+	test.Eq(t, "r1", client.rules[0].GetRuleId())
+}
@@ -0,0 +1,4 @@
+// Package zentral provides functionality to retrieve Santa rules from Zentral
+// and convert them to Workshop format, and to push Workshop rules back to a
+// Zentral configuration.
+package zentral
@@ -0,0 +1,434 @@
+package zentral
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+
+	syncpb "buf.build/gen/go/northpolesec/protos/protocolbuffers/go/sync"
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// ErrConflict is reported for rules on the same target that can't be pushed
+// as a single Zentral rule, since a configuration has at most one rule per
+// target.
+var ErrConflict = errors.New("rules on the same target have different policies")
+
+// ErrWidened is reported for global rules whose Zentral rule is scoped to
+// tags. Pushing them would apply the Zentral rule to every machine.
+var ErrWidened = errors.New("global rule would remove the tag scoping of the Zentral rule")
+
+// ruleRequest is the body of a rule create or update request. Scoping fields
+// are always sent so that an update sets exactly the scoping of the rule.
+type ruleRequest struct {
+	ConfigurationID       int      `json:"configuration"`
+	TargetType            string   `json:"target_type"`
+	TargetIdentifier      string   `json:"target_identifier"`
+	Policy                string   `json:"policy"`
+	CustomMsg             string   `json:"custom_msg"`
+	Description           string   `json:"description"`
+	SerialNumbers         []string `json:"serial_numbers"`
+	ExcludedSerialNumbers []string `json:"excluded_serial_numbers"`
+	PrimaryUsers          []string `json:"primary_users"`
+	ExcludedPrimaryUsers  []string `json:"excluded_primary_users"`
+	Tags                  []int    `json:"tags"`
+	ExcludedTags          []int    `json:"excluded_tags"`
+}
+
+func newRuleRequest(zenRule Rule) ruleRequest {
+	orEmpty := func(s []string) []string { return append([]string{}, s...) }
+	return ruleRequest{
+		ConfigurationID:       zenRule.ConfigurationID,
+		TargetType:            zenRule.TargetType,
+		TargetIdentifier:      zenRule.TargetIdentifier,
+		Policy:                zenRule.Policy,
+		CustomMsg:             zenRule.CustomMsg,
+		Description:           zenRule.Description,
+		SerialNumbers:         orEmpty(zenRule.SerialNumbers),
+		ExcludedSerialNumbers: orEmpty(zenRule.ExcludedSerialNumbers),
+		PrimaryUsers:          orEmpty(zenRule.PrimaryUsers),
+		ExcludedPrimaryUsers:  orEmpty(zenRule.ExcludedPrimaryUsers),
+		Tags:                  append([]int{}, zenRule.Tags...),
+		ExcludedTags:          append([]int{}, zenRule.ExcludedTags...),
+	}
+}
+
+// CreateRule creates a Santa rule in the rule's configuration and returns the
+// created rule.
+func (c *Client) CreateRule(ctx context.Context, zenRule Rule) (Rule, error) {
+	return c.sendRule(ctx, http.MethodPost, "/api/santa/rules/", zenRule)
+}
+
+// UpdateRule replaces the Santa rule with the rule's ID and returns the
+// updated rule.
+func (c *Client) UpdateRule(ctx context.Context, zenRule Rule) (Rule, error) {
+	return c.sendRule(ctx, http.MethodPut, fmt.Sprintf("/api/santa/rules/%d/", zenRule.ID), zenRule)
+}
+
+// DeleteRule deletes the Santa rule with the given ID.
+func (c *Client) DeleteRule(ctx context.Context, id int) error {
+	resp, err := c.makeRequest(ctx, http.MethodDelete, fmt.Sprintf("/api/santa/rules/%d/", id), nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (c *Client) sendRule(ctx context.Context, method, endpoint string, zenRule Rule) (Rule, error) {
+	resp, err := c.makeRequest(ctx, method, endpoint, newRuleRequest(zenRule))
+	if err != nil {
+		return Rule{}, err
+	}
+	defer resp.Body.Close()
+
+	var sent Rule
+	if err := json.NewDecoder(resp.Body).Decode(&sent); err != nil {
+		return Rule{}, fmt.Errorf("failed to parse API response: %w", err)
+	}
+	return sent, nil
+}
+
+// FromWorkshopRules converts Workshop rules to Zentral rules of a
+// configuration. Workshop rules on the same target are merged into one
+// Zentral rule: a global rule applies to every machine, and rules tagged with
+// Zentral tag names, looked up in tagIDs, are scoped to all of their tags.
+// The first rule's message and comment are kept. Rules with a host tag, an
+// unknown tag or a policy Zentral doesn't support, and rules on the same
+// target with different policies, are skipped and reported in a
+// rulehelpers.RecordErrors error alongside the remaining rules.
+func FromWorkshopRules(rules []*apipb.Rule, configurationID int, tagIDs map[string]int) ([]Rule, error) {
+	var (
+		zenRules   []Rule
+		global     []bool
+		recordErrs rulehelpers.RecordErrors
+	)
+	index := map[targetKey]int{}
+	conflicts := map[targetKey]bool{}
+
+	for i, r := range rules {
+		fail := func(err error) {
+			recordErrs = append(recordErrs, &rulehelpers.RecordError{
+				Location:   fmt.Sprintf("rule %d", i),
+				Identifier: r.GetIdentifier(),
+				Err:        err,
+			})
+		}
+
+		if r.GetRuleType() == syncpb.RuleType_RULETYPE_UNKNOWN {
+			fail(fmt.Errorf("unknown rule type: %s", r.GetRuleType()))
+			continue
+		}
+		switch r.GetPolicy() {
+		case syncpb.Policy_ALLOWLIST, syncpb.Policy_ALLOWLIST_COMPILER, syncpb.Policy_BLOCKLIST, syncpb.Policy_SILENT_BLOCKLIST:
+		default:
+			fail(fmt.Errorf("policy %s can't be pushed to Zentral", r.GetPolicy()))
+			continue
+		}
+
+		isGlobal := r.GetTag() == "" || strings.EqualFold(r.GetTag(), "global")
+		var tags []int
+		if !isGlobal {
+			id, ok := tagIDs[r.GetTag()]
+			if !ok {
+				fail(fmt.Errorf("no Zentral tag named %q", r.GetTag()))
+				continue
+			}
+			tags = []int{id}
+		}
+
+		zenRule := Rule{
+			TargetType:       r.GetRuleType().String(),
+			TargetIdentifier: r.GetIdentifier(),
+			Policy:           r.GetPolicy().String(),
+			CustomMsg:        r.GetCustomMsg(),
+			Description:      r.GetComment(),
+			ConfigurationID:  configurationID,
+			Tags:             tags,
+		}
+
+		key := targetKey{zenRule.TargetType, zenRule.TargetIdentifier}
+		j, ok := index[key]
+		if !ok {
+			index[key] = len(zenRules)
+			zenRules = append(zenRules, zenRule)
+			global = append(global, isGlobal)
+			continue
+		}
+
+		existing := &zenRules[j]
+		if existing.Policy != zenRule.Policy {
+			if !conflicts[key] {
+				conflicts[key] = true
+				fail(fmt.Errorf("%w: %s and %s", ErrConflict, existing.Policy, zenRule.Policy))
+			}
+			continue
+		}
+
+		// A global rule already applies to the machines of any tag.
+		switch {
+		case global[j]:
+		case isGlobal:
+			global[j] = true
+			existing.Tags = nil
+		case !slices.Contains(existing.Tags, tags[0]):
+			existing.Tags = append(existing.Tags, tags[0])
+		}
+	}
+
+	kept := zenRules[:0]
+	for _, zenRule := range zenRules {
+		if !conflicts[targetKey{zenRule.TargetType, zenRule.TargetIdentifier}] {
+			slices.Sort(zenRule.Tags)
+			kept = append(kept, zenRule)
+		}
+	}
+	return kept, recordErrs.Err()
+}
+
+// RuleUpdate is an existing Zentral rule that is replaced by Rule.
+type RuleUpdate struct {
+	Rule     Rule
+	Existing Rule
+}
+
+// Changes returns the names of the fields that differ between the rules.
+// Differences in serial number, primary user or excluded tag scoping are
+// reported as "scope".
+func (u RuleUpdate) Changes() []string {
+	var changes []string
+	if u.Rule.Policy != u.Existing.Policy {
+		changes = append(changes, "policy")
+	}
+	if u.Rule.CustomMsg != u.Existing.CustomMsg {
+		changes = append(changes, "custom_msg")
+	}
+	if u.Rule.Description != u.Existing.Description {
+		changes = append(changes, "description")
+	}
+	if !slices.Equal(sortedInts(u.Rule.Tags), sortedInts(u.Existing.Tags)) {
+		changes = append(changes, "tags")
+	}
+	if !slices.Equal(u.Rule.SerialNumbers, u.Existing.SerialNumbers) ||
+		!slices.Equal(u.Rule.ExcludedSerialNumbers, u.Existing.ExcludedSerialNumbers) ||
+		!slices.Equal(u.Rule.PrimaryUsers, u.Existing.PrimaryUsers) ||
+		!slices.Equal(u.Rule.ExcludedPrimaryUsers, u.Existing.ExcludedPrimaryUsers) ||
+		!slices.Equal(sortedInts(u.Rule.ExcludedTags), sortedInts(u.Existing.ExcludedTags)) {
+		changes = append(changes, "scope")
+	}
+	return changes
+}
+
+func sortedInts(s []int) []int {
+	return slices.Sorted(slices.Values(s))
+}
+
+// PushPlan describes the changes needed to make the rules of a Zentral
+// configuration match a rule set.
+type PushPlan struct {
+	ConfigurationID int
+	Create          []Rule
+	Update          []RuleUpdate
+	Unchanged       []Rule
+	// Delete holds the existing rules missing from the rule set. It is only
+	// filled in when deleting missing rules was requested.
+	Delete []Rule
+	// Invalid holds the rules of the rule set that can't be pushed.
+	Invalid rulehelpers.RecordErrors
+}
+
+// DiffRules compares the rules to push with the existing rules of the
+// configuration. Rules are matched on their target type and identifier.
+// Updated rules keep the serial number, primary user and excluded tag scoping
+// of the existing rule, which Workshop rules have no equivalent for; a global
+// rule whose existing rule is scoped to tags is reported as invalid rather
+// than widened. When deleteMissing is set, existing rules selected by filter
+// but missing from rules are deleted, except those with the identifier of an
+// invalid record: a record that couldn't be read says nothing about whether
+// its rule should go. If an invalid record has no identifier, no rule is
+// deleted.
+func DiffRules(rules, existing []Rule, invalid rulehelpers.RecordErrors, filter rulehelpers.Filter, deleteMissing bool) *PushPlan {
+	plan := &PushPlan{Invalid: slices.Clone(invalid)}
+	byKey := map[targetKey]Rule{}
+	for _, r := range existing {
+		byKey[targetKey{strings.ToUpper(r.TargetType), r.TargetIdentifier}] = r
+	}
+
+	pushed := map[targetKey]bool{}
+	for _, r := range rules {
+		key := targetKey{strings.ToUpper(r.TargetType), r.TargetIdentifier}
+		pushed[key] = true
+		plan.ConfigurationID = r.ConfigurationID
+
+		e, ok := byKey[key]
+		if !ok {
+			plan.Create = append(plan.Create, r)
+			continue
+		}
+		if len(r.Tags) == 0 && len(e.Tags) > 0 {
+			plan.Invalid = append(plan.Invalid, &rulehelpers.RecordError{
+				Location:   fmt.Sprintf("zentral rule %d", e.ID),
+				Identifier: e.TargetIdentifier,
+				Err:        ErrWidened,
+			})
+			continue
+		}
+		r.ID = e.ID
+		r.SerialNumbers = e.SerialNumbers
+		r.ExcludedSerialNumbers = e.ExcludedSerialNumbers
+		r.PrimaryUsers = e.PrimaryUsers
+		r.ExcludedPrimaryUsers = e.ExcludedPrimaryUsers
+		r.ExcludedTags = e.ExcludedTags
+		if u := (RuleUpdate{Rule: r, Existing: e}); len(u.Changes()) > 0 {
+			plan.Update = append(plan.Update, u)
+		} else {
+			plan.Unchanged = append(plan.Unchanged, e)
+		}
+	}
+
+	kept := map[string]bool{}
+	for _, recordErr := range invalid {
+		if recordErr.Identifier == "" {
+			deleteMissing = false
+		}
+		kept[strings.ToLower(recordErr.Identifier)] = true
+	}
+
+	if deleteMissing {
+		for _, e := range existing {
+			if !pushed[targetKey{strings.ToUpper(e.TargetType), e.TargetIdentifier}] && !kept[strings.ToLower(e.TargetIdentifier)] && e.selectedBy(filter) {
+				plan.Delete = append(plan.Delete, e)
+			}
+		}
+	}
+	return plan
+}
+
+// selectedBy reports whether filter selects the rule. Rules with a target
+// type or policy filters can't name are never selected, unless the filter
+// selects every rule.
+func (zenRule Rule) selectedBy(filter rulehelpers.Filter) bool {
+	if len(filter.RuleTypes) == 0 && len(filter.Policies) == 0 {
+		return true
+	}
+	r, err := rulehelpers.NewRule(zenRule.TargetType, zenRule.Policy, zenRule.TargetIdentifier)
+	return err == nil && filter.Match(r)
+}
+
+// PlanPush converts rules to Zentral rules of the configuration with the
+// given ID and compares them with its existing rules. invalid holds the
+// records the rules were read without; they, and the rules that can't be
+// pushed, are reported in the plan's Invalid records and keep their Zentral
+// rules from being deleted. filter is the filter rules were selected with;
+// existing rules it doesn't select are never deleted.
+func (c *Client) PlanPush(ctx context.Context, configurationID int, rules []*apipb.Rule, invalid rulehelpers.RecordErrors, filter rulehelpers.Filter, deleteMissing bool) (*PushPlan, error) {
+	existing, err := c.GetRules(ctx, "", "", configurationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rules from Zentral: %w", err)
+	}
+
+	// Tag IDs are only needed, and only fetched, for tagged rules.
+	var tagIDs map[string]int
+	if slices.ContainsFunc(rules, func(r *apipb.Rule) bool { return r.GetTag() != "" }) {
+		tags, err := c.GetTags(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tags from Zentral: %w", err)
+		}
+		tagIDs = make(map[string]int, len(tags))
+		for _, tag := range tags {
+			tagIDs[tag.Name] = tag.ID
+		}
+	}
+
+	zenRules, err := FromWorkshopRules(rules, configurationID, tagIDs)
+	var recordErrs rulehelpers.RecordErrors
+	if err != nil && !errors.As(err, &recordErrs) {
+		return nil, err
+	}
+
+	invalid = append(append(rulehelpers.RecordErrors{}, invalid...), recordErrs...)
+	plan := DiffRules(zenRules, existing, invalid, filter, deleteMissing)
+	plan.ConfigurationID = configurationID
+	return plan, nil
+}
+
+// Push applies the creates, updates and deletes of plan. Every change is
+// attempted; it returns the number of changes applied and the errors of those
+// that failed.
+func (c *Client) Push(ctx context.Context, plan *PushPlan) (int, error) {
+	applied := 0
+	var errs []error
+	record := func(r Rule, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", describeRule(r), err))
+			return
+		}
+		applied++
+	}
+
+	for _, r := range plan.Create {
+		_, err := c.CreateRule(ctx, r)
+		record(r, err)
+	}
+	for _, u := range plan.Update {
+		_, err := c.UpdateRule(ctx, u.Rule)
+		record(u.Rule, err)
+	}
+	for _, r := range plan.Delete {
+		record(r, c.DeleteRule(ctx, r.ID))
+	}
+	return applied, errors.Join(errs...)
+}
+
+// WriteText writes a human-readable summary of the plan to w. Unchanged rules
+// are only counted.
+func (p *PushPlan) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Zentral configuration %d: %d to create, %d to update, %d to delete, %d unchanged, %d invalid\n",
+		p.ConfigurationID, len(p.Create), len(p.Update), len(p.Delete), len(p.Unchanged), len(p.Invalid))
+
+	if len(p.Create) > 0 {
+		fmt.Fprintf(&b, "\nTo create:\n")
+		for _, r := range p.Create {
+			fmt.Fprintf(&b, "  + %s\n", describeRule(r))
+		}
+	}
+
+	if len(p.Update) > 0 {
+		fmt.Fprintf(&b, "\nTo update:\n")
+		for _, u := range p.Update {
+			fmt.Fprintf(&b, "  ~ %s (%s)\n", describeRule(u.Rule), strings.Join(u.Changes(), ", "))
+		}
+	}
+
+	if len(p.Delete) > 0 {
+		fmt.Fprintf(&b, "\nTo delete:\n")
+		for _, r := range p.Delete {
+			fmt.Fprintf(&b, "  - %s\n", describeRule(r))
+		}
+	}
+
+	if len(p.Invalid) > 0 {
+		fmt.Fprintf(&b, "\nInvalid:\n")
+		for _, invalid := range p.Invalid {
+			fmt.Fprintf(&b, "  ! %v\n", invalid)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func describeRule(r Rule) string {
+	if r.ID == 0 {
+		return fmt.Sprintf("%s %s %s", r.TargetType, r.Policy, r.TargetIdentifier)
+	}
+	return fmt.Sprintf("%d %s %s %s", r.ID, r.TargetType, r.Policy, r.TargetIdentifier)
+}
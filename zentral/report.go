@@ -0,0 +1,380 @@
+package zentral
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Target is a binary, certificate, team ID, signing ID or CDHash known to
+// Zentral.
+type Target struct {
+	Type       string `json:"type"`
+	Identifier string `json:"identifier"`
+	// Name describes the target, e.g. a binary's file name or a certificate's
+	// common name.
+	Name string `json:"name"`
+}
+
+// Ballot is a user's votes on a target.
+type Ballot struct {
+	ID     int    `json:"id"`
+	Target Target `json:"target"`
+	Votes  []Vote `json:"votes"`
+}
+
+// Vote is a ballot's vote in one configuration.
+type Vote struct {
+	ConfigurationID int  `json:"configuration"`
+	WasYesVote      bool `json:"was_yes_vote"`
+	Weight          int  `json:"weight"`
+}
+
+// GetTargets retrieves the Santa targets from Zentral API.
+func (c *Client) GetTargets(ctx context.Context) ([]Target, error) {
+	return getAll[Target](ctx, c, "/api/santa/targets/")
+}
+
+// GetBallots retrieves the Santa ballots from Zentral API.
+func (c *Client) GetBallots(ctx context.Context) ([]Ballot, error) {
+	return getAll[Ballot](ctx, c, "/api/santa/ballots/")
+}
+
+// SettingReport describes how a Zentral configuration setting maps to
+// Workshop.
+type SettingReport struct {
+	Key   string
+	Value any
+
+	// Workshop is the Workshop sync setting the Zentral setting maps to. It
+	// is empty if Workshop has no equivalent.
+	Workshop string
+
+	// Note explains a setting with no Workshop equivalent.
+	Note string
+}
+
+// configSettings maps the Zentral configuration settings with a Workshop
+// equivalent to the sync setting.
+var configSettings = map[string]string{
+	"client_mode":             "client_mode",
+	"batch_size":              "batch_size",
+	"enable_bundles":          "enable_bundles",
+	"enable_transitive_rules": "enable_transitive_rules",
+	"allowed_path_regex":      "allowed_path_regex",
+	"blocked_path_regex":      "blocked_path_regex",
+	"block_usb_mount":         "block_usb_mount",
+	"remount_usb_mode":        "remount_usb_mode",
+}
+
+const noVoting = "Workshop has no ballot voting"
+
+// configNotes explains the Zentral configuration settings with no Workshop
+// equivalent.
+var configNotes = map[string]string{
+	"full_sync_interval":              "set FullSyncInterval in the Santa configuration profile",
+	"allow_unknown_shard":             "Workshop can't roll settings out to a share of machines",
+	"enable_all_event_upload_shard":   "Workshop can't roll settings out to a share of machines",
+	"sync_incident_severity":          "Workshop has no incidents",
+	"voting_realm":                    noVoting,
+	"default_ballot_target_types":     noVoting,
+	"default_voting_weight":           noVoting,
+	"banned_threshold":                noVoting,
+	"partially_allowlisted_threshold": noVoting,
+	"globally_allowlisted_threshold":  noVoting,
+}
+
+// settingReports reports how each setting of a configuration maps to
+// Workshop, sorted by key. Uploading all events from every machine, or none,
+// maps to enable_all_event_upload.
+func settingReports(settings map[string]any) []SettingReport {
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	reports := make([]SettingReport, 0, len(keys))
+	for _, key := range keys {
+		r := SettingReport{Key: key, Value: settings[key], Workshop: configSettings[key]}
+		if shard, ok := r.Value.(float64); ok && key == "enable_all_event_upload_shard" && (shard == 0 || shard == 100) {
+			r.Workshop = "enable_all_event_upload"
+		}
+		if r.Workshop == "" {
+			r.Note = configNotes[key]
+		}
+		reports = append(reports, r)
+	}
+	return reports
+}
+
+// RuleReport describes how a Zentral rule maps to Workshop.
+type RuleReport struct {
+	Rule Rule
+
+	// Target is the target the rule applies to, if Zentral knows it.
+	Target *Target
+
+	// Tags are the Workshop tags the rule is imported with, or nil for a
+	// global rule.
+	Tags []string
+
+	// Err is set if the rule can't be imported.
+	Err error
+
+	// YesVotes and NoVotes count the ballots cast on the rule's target in
+	// the rule's configuration.
+	YesVotes, NoVotes int
+}
+
+// ConfigurationReport describes how a Zentral configuration and its rules map
+// to Workshop.
+type ConfigurationReport struct {
+	Configuration Configuration
+	Settings      []SettingReport
+	Rules         []RuleReport
+
+	// VotedTargets are the targets voted on in the configuration that have
+	// no rule.
+	VotedTargets []Target
+}
+
+// Report describes how the Santa configurations, rules and ballots in
+// Zentral map to Workshop.
+type Report struct {
+	Configurations []ConfigurationReport
+}
+
+type targetKey struct {
+	Type       string
+	Identifier string
+}
+
+// Report fetches the rules matching filter with the Santa configurations,
+// targets and ballots, and links them in a migration report. Without a
+// configuration filter, every configuration is reported.
+func (c *Client) Report(ctx context.Context, filter RuleFilter) (*Report, error) {
+	zenRules, tagNames, err := c.GetFilteredRules(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return c.report(ctx, filter, zenRules, tagNames)
+}
+
+// report is like Report for the rules and tag names already returned by
+// GetFilteredRules for filter. The Zentral API returns every target and
+// ballot, so the filter's configuration, target type and identifier are
+// applied to them here.
+func (c *Client) report(ctx context.Context, filter RuleFilter, zenRules []Rule, tagNames map[int]string) (*Report, error) {
+	configs, err := c.GetConfigurations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get configurations from Zentral: %w", err)
+	}
+	targets, err := c.GetTargets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get targets from Zentral: %w", err)
+	}
+	ballots, err := c.GetBallots(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ballots from Zentral: %w", err)
+	}
+
+	var configIDs []int
+	for _, config := range configs {
+		switch {
+		case filter.ConfigurationID != 0 && config.ID != filter.ConfigurationID:
+		case filter.ConfigurationName != "" && config.Name != filter.ConfigurationName:
+		default:
+			configIDs = append(configIDs, config.ID)
+		}
+	}
+
+	reported := map[int]bool{}
+	for _, id := range configIDs {
+		reported[id] = true
+	}
+	for _, r := range zenRules {
+		reported[r.ConfigurationID] = true
+	}
+	targets = slices.DeleteFunc(targets, func(t Target) bool { return !filter.selectsTarget(t) })
+	ballots = slices.DeleteFunc(ballots, func(b Ballot) bool { return !filter.selectsTarget(b.Target) })
+	for i := range ballots {
+		ballots[i].Votes = slices.DeleteFunc(ballots[i].Votes, func(v Vote) bool { return !reported[v.ConfigurationID] })
+	}
+
+	return BuildReport(configs, zenRules, tagNames, targets, ballots, configIDs...), nil
+}
+
+// selectsTarget reports whether the filter's target type and identifier
+// select t.
+func (filter RuleFilter) selectsTarget(t Target) bool {
+	return (filter.TargetType == "" || strings.EqualFold(filter.TargetType, t.Type)) &&
+		(filter.TargetIdentifier == "" || filter.TargetIdentifier == t.Identifier)
+}
+
+// BuildReport links each rule to its configuration, its target and the
+// ballots cast on it. Only the configurations of rules, and those in
+// configIDs, are reported. tagNames names the tags rules are scoped to, as
+// for ConvertToWorkshopRules.
+func BuildReport(configs []Configuration, rules []Rule, tagNames map[int]string, targets []Target, ballots []Ballot, configIDs ...int) *Report {
+	targetsByKey := map[targetKey]*Target{}
+	for i, t := range targets {
+		targetsByKey[targetKey{strings.ToUpper(t.Type), t.Identifier}] = &targets[i]
+	}
+
+	type voteKey struct {
+		configID int
+		target   targetKey
+	}
+	yes, no := map[voteKey]int{}, map[voteKey]int{}
+	voted := map[int][]Target{}
+	for _, b := range ballots {
+		key := targetKey{strings.ToUpper(b.Target.Type), b.Target.Identifier}
+		for _, v := range b.Votes {
+			vk := voteKey{v.ConfigurationID, key}
+			if yes[vk]+no[vk] == 0 {
+				voted[v.ConfigurationID] = append(voted[v.ConfigurationID], b.Target)
+			}
+			if v.WasYesVote {
+				yes[vk]++
+			} else {
+				no[vk]++
+			}
+		}
+	}
+
+	index := map[int]int{}
+	report := &Report{}
+	addConfig := func(id int) *ConfigurationReport {
+		if i, ok := index[id]; ok {
+			return &report.Configurations[i]
+		}
+		config := Configuration{ID: id, Name: fmt.Sprintf("configuration %d", id)}
+		for _, c := range configs {
+			if c.ID == id {
+				config = c
+			}
+		}
+		index[id] = len(report.Configurations)
+		report.Configurations = append(report.Configurations, ConfigurationReport{
+			Configuration: config,
+			Settings:      settingReports(config.Settings),
+		})
+		return &report.Configurations[index[id]]
+	}
+
+	for _, id := range configIDs {
+		addConfig(id)
+	}
+
+	ruled := map[voteKey]bool{}
+	for _, r := range rules {
+		key := targetKey{strings.ToUpper(r.TargetType), r.TargetIdentifier}
+		vk := voteKey{r.ConfigurationID, key}
+		ruled[vk] = true
+
+		rr := RuleReport{
+			Rule:     r,
+			Target:   targetsByKey[key],
+			YesVotes: yes[vk],
+			NoVotes:  no[vk],
+		}
+		converted, err := r.ToWorkshopRules(tagNames)
+		if err != nil {
+			rr.Err = err
+		}
+		for _, wr := range converted {
+			if wr.GetTag() != "" {
+				rr.Tags = append(rr.Tags, wr.GetTag())
+			}
+		}
+
+		c := addConfig(r.ConfigurationID)
+		c.Rules = append(c.Rules, rr)
+	}
+
+	for i := range report.Configurations {
+		c := &report.Configurations[i]
+		for _, t := range voted[c.Configuration.ID] {
+			if !ruled[voteKey{c.Configuration.ID, targetKey{strings.ToUpper(t.Type), t.Identifier}}] {
+				c.VotedTargets = append(c.VotedTargets, t)
+			}
+		}
+	}
+
+	return report
+}
+
+// WriteText writes a human-readable migration report to w.
+func (r *Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+
+	for i, c := range r.Configurations {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+
+		invalid := 0
+		for _, rule := range c.Rules {
+			if rule.Err != nil {
+				invalid++
+			}
+		}
+		fmt.Fprintf(&b, "Configuration %d %q: %d rules, %d can't be imported, %d targets voted on without a rule\n",
+			c.Configuration.ID, c.Configuration.Name, len(c.Rules), invalid, len(c.VotedTargets))
+
+		if len(c.Settings) > 0 {
+			fmt.Fprintf(&b, "\nSettings:\n")
+			for _, s := range c.Settings {
+				switch {
+				case s.Workshop != "":
+					fmt.Fprintf(&b, "  + %s = %v -> %s\n", s.Key, s.Value, s.Workshop)
+				case s.Note != "":
+					fmt.Fprintf(&b, "  - %s = %v (%s)\n", s.Key, s.Value, s.Note)
+				default:
+					fmt.Fprintf(&b, "  - %s = %v (no Workshop equivalent)\n", s.Key, s.Value)
+				}
+			}
+		}
+
+		if len(c.Rules) > 0 {
+			fmt.Fprintf(&b, "\nRules:\n")
+			for _, rule := range c.Rules {
+				mark := "+"
+				if rule.Err != nil {
+					mark = "!"
+				}
+				fmt.Fprintf(&b, "  %s %d %s %s %s", mark, rule.Rule.ID, rule.Rule.TargetType, rule.Rule.Policy, rule.Rule.TargetIdentifier)
+				if rule.Target != nil && rule.Target.Name != "" {
+					fmt.Fprintf(&b, " (%s)", rule.Target.Name)
+				}
+				if len(rule.Tags) > 0 {
+					fmt.Fprintf(&b, ", tags %s", strings.Join(rule.Tags, ", "))
+				}
+				if rule.YesVotes+rule.NoVotes > 0 {
+					fmt.Fprintf(&b, ", %d yes and %d no votes", rule.YesVotes, rule.NoVotes)
+				}
+				if rule.Err != nil {
+					fmt.Fprintf(&b, ": %v", rule.Err)
+				}
+				b.WriteString("\n")
+			}
+		}
+
+		if len(c.VotedTargets) > 0 {
+			fmt.Fprintf(&b, "\nVoted on without a rule (%s):\n", noVoting)
+			for _, t := range c.VotedTargets {
+				fmt.Fprintf(&b, "  - %s %s", t.Type, t.Identifier)
+				if t.Name != "" {
+					fmt.Fprintf(&b, " (%s)", t.Name)
+				}
+				b.WriteString("\n")
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
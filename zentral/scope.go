@@ -0,0 +1,188 @@
+package zentral
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// Configuration is a Santa configuration in Zentral.
+type Configuration struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+
+	// Settings holds the client mode, path regexes, sync and voting settings
+	// of the configuration, keyed by their Zentral API name.
+	Settings map[string]any `json:"-"`
+}
+
+// UnmarshalJSON decodes a configuration, keeping every field other than its
+// ID, name and timestamps in Settings.
+func (config *Configuration) UnmarshalJSON(data []byte) error {
+	type plain Configuration
+	if err := json.Unmarshal(data, (*plain)(config)); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, &config.Settings); err != nil {
+		return err
+	}
+	for _, key := range []string{"id", "name", "created_at", "updated_at"} {
+		delete(config.Settings, key)
+	}
+	return nil
+}
+
+// Tag is a Zentral inventory tag.
+type Tag struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// GetConfigurations retrieves the Santa configurations from Zentral API.
+func (c *Client) GetConfigurations(ctx context.Context) ([]Configuration, error) {
+	return getAll[Configuration](ctx, c, "/api/santa/configurations/")
+}
+
+// ConfigurationID returns the ID of the Santa configuration named name.
+func (c *Client) ConfigurationID(ctx context.Context, name string) (int, error) {
+	configs, err := getAll[Configuration](ctx, c, "/api/santa/configurations/?"+url.Values{"name": {name}}.Encode())
+	if err != nil {
+		return 0, err
+	}
+	for _, config := range configs {
+		if config.Name == name {
+			return config.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("no Zentral configuration named %q", name)
+}
+
+// GetTags retrieves the inventory tags from Zentral API.
+func (c *Client) GetTags(ctx context.Context) ([]Tag, error) {
+	return getAll[Tag](ctx, c, "/api/inventory/tags/")
+}
+
+// RuleFilter selects the rules returned by Client.FetchRules. Zero fields
+// don't filter.
+type RuleFilter struct {
+	TargetType       string
+	TargetIdentifier string
+	ConfigurationID  int
+
+	// ConfigurationName selects the configuration by name instead of by ID.
+	ConfigurationName string
+
+	// Tags keeps only the rules scoped to at least one of these tag names,
+	// and only their scoping to these tags.
+	Tags []string
+}
+
+// String describes the filter as a URL query, with the tags sorted. Filters
+// selecting the same rules the same way have the same description.
+func (filter RuleFilter) String() string {
+	params := url.Values{}
+	for key, value := range map[string]string{
+		"target_type":       filter.TargetType,
+		"target_identifier": filter.TargetIdentifier,
+		"configuration":     filter.ConfigurationName,
+		"tags":              strings.Join(slices.Sorted(slices.Values(filter.Tags)), ","),
+	} {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+	if filter.ConfigurationID != 0 {
+		params.Set("configuration_id", strconv.Itoa(filter.ConfigurationID))
+	}
+	return params.Encode()
+}
+
+// FetchRules retrieves the rules matching filter and converts them to
+// Workshop format. Rules scoped to tags are mapped to Workshop rules with the
+// same tags. Like ConvertToWorkshopRules, it may return a
+// rulehelpers.RecordErrors error alongside the rules that were converted.
+func (c *Client) FetchRules(ctx context.Context, filter RuleFilter) ([]*apipb.Rule, error) {
+	zenRules, tagNames, err := c.GetFilteredRules(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return ConvertToWorkshopRules(zenRules, tagNames)
+}
+
+// GetFilteredRules retrieves the rules matching filter, and the names of the
+// tags they're scoped to for ConvertToWorkshopRules.
+func (c *Client) GetFilteredRules(ctx context.Context, filter RuleFilter) ([]Rule, map[int]string, error) {
+	configID := filter.ConfigurationID
+	if filter.ConfigurationName != "" {
+		if configID != 0 {
+			return nil, nil, errors.New("filter by either configuration ID or name, not both")
+		}
+		id, err := c.ConfigurationID(ctx, filter.ConfigurationName)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to resolve Zentral configuration: %w", err)
+		}
+		configID = id
+	}
+
+	zenRules, err := c.GetRules(ctx, filter.TargetType, filter.TargetIdentifier, configID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get rules from Zentral: %w", err)
+	}
+
+	// Tag names are only needed, and only fetched, for tagged rules.
+	var tagNames map[int]string
+	if len(filter.Tags) > 0 || slices.ContainsFunc(zenRules, func(r Rule) bool { return len(r.Tags) > 0 }) {
+		tags, err := c.GetTags(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get tags from Zentral: %w", err)
+		}
+		tagNames = make(map[int]string, len(tags))
+		for _, tag := range tags {
+			tagNames[tag.ID] = tag.Name
+		}
+	}
+
+	if len(filter.Tags) > 0 {
+		zenRules, err = filterByTags(zenRules, tagNames, filter.Tags)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return zenRules, tagNames, nil
+}
+
+// filterByTags returns the rules scoped to at least one of the named tags,
+// scoped to just those tags so that they aren't imported under the others.
+func filterByTags(zenRules []Rule, tagNames map[int]string, names []string) ([]Rule, error) {
+	ids := map[int]bool{}
+	for _, name := range names {
+		found := false
+		for id, tagName := range tagNames {
+			if tagName == name {
+				ids[id] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no Zentral tag named %q", name)
+		}
+	}
+
+	var filtered []Rule
+	for _, r := range zenRules {
+		r.Tags = slices.DeleteFunc(slices.Clone(r.Tags), func(id int) bool { return !ids[id] })
+		if len(r.Tags) > 0 {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered, nil
+}
@@ -0,0 +1,177 @@
+package zentral
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/northpolesec/santa-rule-importer/internal/textutil"
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/northpolesec/santa-rule-importer/source"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+func init() {
+	source.Register(source.Registration{
+		Name:         "zentral",
+		Description:  "Zentral server",
+		LocationFlag: "zentral-url",
+		Driver:       &driver{},
+	})
+}
+
+type driver struct {
+	url              string
+	targetType       string
+	targetIdentifier string
+	configID         int
+	configName       string
+	tags             string
+	statePath        string
+	report           string
+	timeout          time.Duration
+	maxAttempts      int
+}
+
+func (d *driver) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&d.url, "zentral-url", "", "Zentral base URL (e.g., zentral.example.com)")
+	fs.StringVar(&d.targetType, "zentral-target-type", "", "Filter Zentral rules by target type (BINARY, CERTIFICATE, etc.)")
+	fs.StringVar(&d.targetIdentifier, "zentral-target-identifier", "", "Filter Zentral rules by target identifier")
+	fs.IntVar(&d.configID, "zentral-config-id", 0, "Filter Zentral rules by configuration ID")
+	fs.StringVar(&d.configName, "zentral-config", "", "Filter Zentral rules by configuration name")
+	fs.StringVar(&d.tags, "zentral-tags", "", "Only import Zentral rules scoped to one of these comma separated tags")
+	fs.StringVar(&d.statePath, "zentral-state", "", "Only import Zentral rules created or updated since the import that wrote this state file")
+	fs.StringVar(&d.report, "zentral-report", "", "Write a migration report of the Zentral configurations, rules and ballots to this file, - for stderr")
+	fs.DurationVar(&d.timeout, "zentral-timeout", DefaultTimeout, "Timeout for each request sent to Zentral (0 for no timeout)")
+	fs.IntVar(&d.maxAttempts, "zentral-max-attempts", DefaultMaxAttempts, "Number of times to send a Zentral request that is rate limited or fails with a transient error")
+}
+
+func (d *driver) Open(baseURL string) (source.Source, error) {
+	token := os.Getenv("ZENTRAL_API_KEY")
+	if token == "" {
+		return nil, errors.New("please set ZENTRAL_API_KEY environment variable for Zentral imports")
+	}
+
+	if !strings.HasPrefix(baseURL, "http") {
+		baseURL = "https://" + baseURL
+	}
+
+	client := NewClient(baseURL, token)
+	client.HTTPClient.Timeout = d.timeout
+	client.MaxAttempts = d.maxAttempts
+
+	filter := RuleFilter{
+		TargetType:        d.targetType,
+		TargetIdentifier:  d.targetIdentifier,
+		ConfigurationID:   d.configID,
+		ConfigurationName: d.configName,
+		Tags:              textutil.SplitList(d.tags),
+	}
+	f := fetcher{client: client, filter: filter, report: d.report}
+	if d.statePath != "" {
+		return &incrementalSource{fetcher: f, path: d.statePath}, nil
+	}
+	return &rulesSource{fetcher: f}, nil
+}
+
+// fetcher fetches the Zentral rules matching filter, and writes a migration
+// report of them to report if it is set.
+type fetcher struct {
+	client *Client
+	filter RuleFilter
+	report string
+}
+
+func (f *fetcher) fetch(ctx context.Context) ([]Rule, map[int]string, error) {
+	zenRules, tagNames, err := f.client.GetFilteredRules(ctx, f.filter)
+	if err != nil || f.report == "" {
+		return zenRules, tagNames, err
+	}
+
+	report, err := f.client.report(ctx, f.filter, zenRules, tagNames)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := textutil.WriteFile(f.report, report.WriteText); err != nil {
+		return nil, nil, fmt.Errorf("failed to write Zentral report: %w", err)
+	}
+	return zenRules, tagNames, nil
+}
+
+// rulesSource returns the Zentral rules matching filter.
+type rulesSource struct {
+	fetcher
+	locs rulehelpers.Locations
+}
+
+func (s *rulesSource) Rules(ctx context.Context) ([]*apipb.Rule, error) {
+	zenRules, tagNames, err := s.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, locs, err := convertRules(zenRules, tagNames)
+	s.locs = locs
+	return rules, err
+}
+
+func (s *rulesSource) Locations() rulehelpers.Locations {
+	return s.locs
+}
+
+// incrementalSource only returns the rules created or updated since the
+// import that wrote the state file at path. The state is saved by Commit.
+type incrementalSource struct {
+	fetcher
+	path string
+	next *State
+	locs rulehelpers.Locations
+}
+
+func (s *incrementalSource) Rules(ctx context.Context) ([]*apipb.Rule, error) {
+	state, err := LoadState(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Zentral state: %w", err)
+	}
+
+	// The high-water mark of one filter says nothing about the rules of
+	// another.
+	filter := s.filter.String()
+	if !state.Empty() && state.Filter != filter {
+		return nil, fmt.Errorf("%s was written for Zentral rules filtered by %q, not %q; use a separate state file for each filter", s.path, state.Filter, filter)
+	}
+	state.Filter = filter
+
+	zenRules, tagNames, err := s.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	changed, deleted := state.Changes(zenRules)
+	log.Printf("%d of %d Zentral rules created or updated since the last import\n", len(changed), len(zenRules))
+	for _, r := range deleted {
+		log.Printf("Zentral rule %d (%s %s %s) was deleted since the last import\n", r.ID, r.TargetType, r.Policy, r.TargetIdentifier)
+	}
+
+	s.next = state.Next(zenRules)
+	rules, locs, err := convertRules(changed, tagNames)
+	s.locs = locs
+	return rules, err
+}
+
+func (s *incrementalSource) Locations() rulehelpers.Locations {
+	return s.locs
+}
+
+func (s *incrementalSource) Commit() error {
+	if s.next == nil {
+		return nil
+	}
+	return s.next.Save(s.path)
+}
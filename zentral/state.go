@@ -0,0 +1,155 @@
+package zentral
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// State records the Zentral rules seen by an incremental import, so that the
+// next run only imports the rules created or updated since, and can tell
+// which rules were deleted.
+type State struct {
+	// Filter describes the rule filter of the imports that wrote the state,
+	// as returned by RuleFilter.String.
+	Filter string `json:"filter"`
+
+	// UpdatedAt is the latest updated_at of the rules seen.
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Rules maps the IDs of the rules seen to their target.
+	Rules map[int]StateRule `json:"rules"`
+}
+
+// StateRule identifies a rule recorded in a State.
+type StateRule struct {
+	TargetType       string `json:"target_type"`
+	TargetIdentifier string `json:"target_identifier"`
+	Policy           string `json:"policy"`
+}
+
+// DeletedRule is a rule recorded in a State that is no longer in Zentral.
+type DeletedRule struct {
+	ID int
+	StateRule
+}
+
+// LoadState reads the state file at path. A missing file is an empty state,
+// with which every rule is imported.
+func LoadState(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &State{Rules: map[int]StateRule{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if s.Rules == nil {
+		s.Rules = map[int]StateRule{}
+	}
+	return &s, nil
+}
+
+// Save writes the state to path, replacing it atomically.
+func (s *State) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Empty reports whether the state records no import.
+func (s *State) Empty() bool {
+	return len(s.Rules) == 0 && s.UpdatedAt.IsZero()
+}
+
+// timestampLayouts are the updated_at formats accepted from Zentral.
+// Timestamps without a time zone are taken to be UTC.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+}
+
+func parseTimestamp(value string) (time.Time, error) {
+	var err error
+	for _, layout := range timestampLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// Changes compares the rules currently in Zentral with the state. It returns
+// the rules that are new or were updated after the state's high-water mark,
+// and the rules of the state that were deleted, sorted by ID. Rules whose
+// updated_at can't be parsed are always returned.
+func (s *State) Changes(zenRules []Rule) (changed []Rule, deleted []DeletedRule) {
+	current := make(map[int]bool, len(zenRules))
+	for _, r := range zenRules {
+		current[r.ID] = true
+
+		_, seen := s.Rules[r.ID]
+		updatedAt, err := parseTimestamp(r.UpdatedAt)
+		if !seen || err != nil || updatedAt.After(s.UpdatedAt) {
+			changed = append(changed, r)
+		}
+	}
+
+	for id, r := range s.Rules {
+		if !current[id] {
+			deleted = append(deleted, DeletedRule{ID: id, StateRule: r})
+		}
+	}
+	sort.Slice(deleted, func(i, j int) bool { return deleted[i].ID < deleted[j].ID })
+
+	return changed, deleted
+}
+
+// Next returns the state after importing zenRules, the full set of rules
+// currently in Zentral.
+func (s *State) Next(zenRules []Rule) *State {
+	next := &State{
+		Filter:    s.Filter,
+		UpdatedAt: s.UpdatedAt,
+		Rules:     make(map[int]StateRule, len(zenRules)),
+	}
+	for _, r := range zenRules {
+		next.Rules[r.ID] = StateRule{
+			TargetType:       r.TargetType,
+			TargetIdentifier: r.TargetIdentifier,
+			Policy:           r.Policy,
+		}
+		if updatedAt, err := parseTimestamp(r.UpdatedAt); err == nil && updatedAt.After(next.UpdatedAt) {
+			next.UpdatedAt = updatedAt
+		}
+	}
+	return next
+}
@@ -0,0 +1,429 @@
+package zentral
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"google.golang.org/protobuf/proto"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+// Rule represents a Santa rule from Zentral API response
+type Rule struct {
+	ID               int    `json:"id"`
+	TargetType       string `json:"target_type"`
+	TargetIdentifier string `json:"target_identifier"`
+	Policy           string `json:"policy"`
+	CustomMsg        string `json:"custom_msg"`
+	Description      string `json:"description"`
+	ConfigurationID  int    `json:"configuration"`
+	CreatedAt        string `json:"created_at"`
+	UpdatedAt        string `json:"updated_at"`
+
+	// Scoping. A rule with none of these set applies to every machine of its
+	// configuration. Tags are Zentral tag IDs.
+	SerialNumbers         []string `json:"serial_numbers"`
+	ExcludedSerialNumbers []string `json:"excluded_serial_numbers"`
+	PrimaryUsers          []string `json:"primary_users"`
+	ExcludedPrimaryUsers  []string `json:"excluded_primary_users"`
+	Tags                  []int    `json:"tags"`
+	ExcludedTags          []int    `json:"excluded_tags"`
+}
+
+// Page represents a page of a paginated response from Zentral API
+type Page[T any] struct {
+	Count    int     `json:"count"`
+	Next     *string `json:"next"`
+	Previous *string `json:"previous"`
+	Results  []T     `json:"results"`
+}
+
+// APIResponse represents the paginated response from Zentral API
+type APIResponse = Page[Rule]
+
+// Defaults for the Client options.
+const (
+	DefaultTimeout        = 30 * time.Second
+	DefaultMaxAttempts    = 5
+	DefaultInitialBackoff = time.Second
+	DefaultMaxBackoff     = 30 * time.Second
+)
+
+// Client represents a Zentral API client
+type Client struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+
+	// MaxAttempts is the number of times a request is sent before giving up
+	// on a rate limited or transient error. Zero means DefaultMaxAttempts.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry when the server
+	// doesn't send Retry-After. It doubles on each further retry up to
+	// MaxBackoff, with jitter applied. Retry-After delays are also capped at
+	// MaxBackoff. Zero values use DefaultInitialBackoff and DefaultMaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// NewClient creates a new Zentral API client
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		BaseURL:    baseURL,
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+	}
+}
+
+// APIError is returned for requests the Zentral API answered with an error
+// status.
+type APIError struct {
+	StatusCode int
+	Body       string
+
+	// RetryAfter is the delay requested by the server's Retry-After header,
+	// or zero if it sent none.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// Temporary reports whether the request may succeed if retried: the client
+// was rate limited or the server, or a proxy in front of it, failed.
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests ||
+		(e.StatusCode >= 500 && e.StatusCode != http.StatusNotImplemented)
+}
+
+// makeRequest makes an authenticated HTTP request to the Zentral API, sending
+// body as JSON unless it is nil. Rate limited requests, transient server
+// errors and network errors are retried with exponential backoff, honoring
+// Retry-After. Since a POST may have created its object before failing, POST
+// requests are only retried when rate limited.
+func (c *Client) makeRequest(ctx context.Context, method, endpoint string, body any) (*http.Response, error) {
+	// Parse base URL
+	baseURL, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse base URL: %w", err)
+	}
+
+	// Parse endpoint (which may contain query parameters)
+	endpointURL, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse endpoint: %w", err)
+	}
+
+	// Resolve the endpoint against the base URL
+	fullURL := baseURL.ResolveReference(endpointURL)
+
+	var payload []byte
+	if body != nil {
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("failed to encode request body: %w", err)
+		}
+	}
+
+	maxAttempts := cmp.Or(c.MaxAttempts, DefaultMaxAttempts)
+	backoff := cmp.Or(c.InitialBackoff, DefaultInitialBackoff)
+	maxBackoff := cmp.Or(c.MaxBackoff, DefaultMaxBackoff)
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.do(ctx, method, fullURL.String(), payload)
+		if err == nil {
+			return resp, nil
+		}
+
+		var apiErr *APIError
+		isAPIErr := errors.As(err, &apiErr)
+		if (isAPIErr && !apiErr.Temporary()) || ctx.Err() != nil || attempt >= maxAttempts {
+			return nil, err
+		}
+		if method == http.MethodPost && (!isAPIErr || apiErr.StatusCode != http.StatusTooManyRequests) {
+			return nil, err
+		}
+
+		// Jitter the backoff, unless Zentral asked for a delay, so that
+		// concurrent clients don't retry in lockstep.
+		delay := backoff/2 + rand.N(backoff/2+1)
+		if isAPIErr && apiErr.RetryAfter > 0 {
+			delay = min(apiErr.RetryAfter, maxBackoff)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// do sends a single request, returning an *APIError for any status other
+// than 200 OK.
+func (c *Client) do(ctx context.Context, method, url string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Token "+c.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	return resp, nil
+}
+
+// parseRetryAfter returns the delay given by a Retry-After header, in seconds
+// or as an HTTP date, or zero if there is none.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+// GetRules retrieves all Santa rules from Zentral API with optional filters
+func (c *Client) GetRules(ctx context.Context, targetType, targetIdentifier string, configurationID int) ([]Rule, error) {
+	endpoint := "/api/santa/rules/"
+
+	// Add query parameters if provided
+	params := url.Values{}
+	if targetType != "" {
+		params.Set("target_type", targetType)
+	}
+	if targetIdentifier != "" {
+		params.Set("target_identifier", targetIdentifier)
+	}
+	if configurationID > 0 {
+		params.Set("configuration_id", strconv.Itoa(configurationID))
+	}
+
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+
+	return getAll[Rule](ctx, c, endpoint)
+}
+
+// getAll fetches every item of a list endpoint, following pagination.
+func getAll[T any](ctx context.Context, c *Client, endpoint string) ([]T, error) {
+	var all []T
+	for {
+		resp, err := c.makeRequest(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response body: %w", err)
+		}
+
+		// Some list endpoints aren't paginated.
+		if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+			var items []T
+			if err := json.Unmarshal(body, &items); err != nil {
+				return nil, fmt.Errorf("failed to parse API response: %w", err)
+			}
+			return append(all, items...), nil
+		}
+
+		var page Page[T]
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("failed to parse API response: %w", err)
+		}
+
+		all = append(all, page.Results...)
+
+		// Check if there are more pages
+		if page.Next == nil {
+			break
+		}
+
+		// Parse the next URL to get just the path and query
+		nextURL, err := url.Parse(*page.Next)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse next URL: %w", err)
+		}
+		endpoint = nextURL.Path + "?" + nextURL.RawQuery
+	}
+
+	return all, nil
+}
+
+// ErrScoped is reported for rules scoped in a way Workshop rules can't
+// represent. They are never imported as global rules.
+var ErrScoped = errors.New("scoping can't be represented in Workshop")
+
+// maxTagLen is the longest tag Workshop accepts. The tag field of the
+// workshop.v1.Rule message is documented as "Use any other string, with a max
+// length of 42, to create custom rule categories".
+const maxTagLen = 42
+
+// Scoped reports whether the rule only applies to some of the machines of its
+// configuration.
+func (zenRule Rule) Scoped() bool {
+	return len(zenRule.SerialNumbers) > 0 || len(zenRule.ExcludedSerialNumbers) > 0 ||
+		len(zenRule.PrimaryUsers) > 0 || len(zenRule.ExcludedPrimaryUsers) > 0 ||
+		len(zenRule.Tags) > 0 || len(zenRule.ExcludedTags) > 0
+}
+
+// unrepresentable returns the scoping fields of the rule that have no
+// Workshop equivalent.
+func (zenRule Rule) unrepresentable() []string {
+	var fields []string
+	for _, f := range []struct {
+		name string
+		set  bool
+	}{
+		{"serial_numbers", len(zenRule.SerialNumbers) > 0},
+		{"excluded_serial_numbers", len(zenRule.ExcludedSerialNumbers) > 0},
+		{"primary_users", len(zenRule.PrimaryUsers) > 0},
+		{"excluded_primary_users", len(zenRule.ExcludedPrimaryUsers) > 0},
+		{"excluded_tags", len(zenRule.ExcludedTags) > 0},
+	} {
+		if f.set {
+			fields = append(fields, f.name)
+		}
+	}
+	return fields
+}
+
+// ToWorkshopRule converts an unscoped Zentral rule to Workshop format. The
+// description is used as the comment. Scoped rules are rejected with
+// ErrScoped; use ToWorkshopRules to convert rules scoped by tag.
+func (zenRule Rule) ToWorkshopRule() (*apipb.Rule, error) {
+	if zenRule.Scoped() {
+		return nil, fmt.Errorf("%w: rule only applies to some machines", ErrScoped)
+	}
+	rules, err := zenRule.ToWorkshopRules(nil)
+	if err != nil {
+		return nil, err
+	}
+	return rules[0], nil
+}
+
+// ToWorkshopRules converts a Zentral rule to Workshop format. A rule scoped to
+// tags becomes one Workshop rule for each tag, tagged with the tag's name in
+// tagNames. Rules scoped by serial number, primary user or excluded tags are
+// rejected with ErrScoped.
+func (zenRule Rule) ToWorkshopRules(tagNames map[int]string) ([]*apipb.Rule, error) {
+	if fields := zenRule.unrepresentable(); len(fields) > 0 {
+		return nil, fmt.Errorf("%w: scoped by %s", ErrScoped, strings.Join(fields, ", "))
+	}
+
+	r, err := rulehelpers.NewRule(zenRule.TargetType, zenRule.Policy, zenRule.TargetIdentifier)
+	if err != nil {
+		return nil, err
+	}
+
+	r.CustomMsg = zenRule.CustomMsg
+	r.Comment = zenRule.Description
+	if len(zenRule.Tags) == 0 {
+		return []*apipb.Rule{r}, nil
+	}
+
+	rules := make([]*apipb.Rule, 0, len(zenRule.Tags))
+	for _, id := range zenRule.Tags {
+		name, ok := tagNames[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown tag %d", ErrScoped, id)
+		}
+		if name == "" || strings.EqualFold(name, "global") || strings.HasPrefix(name, "host:") || len(name) > maxTagLen {
+			return nil, fmt.Errorf("%w: tag %q is not a valid Workshop tag", ErrScoped, name)
+		}
+		tagged := proto.Clone(r).(*apipb.Rule)
+		tagged.Tag = name
+		rules = append(rules, tagged)
+	}
+	return rules, nil
+}
+
+// ConvertToWorkshopRules converts Zentral rules to Workshop format, mapping
+// rules scoped to tags as described by Rule.ToWorkshopRules. Rules with an
+// unknown target type or policy, or scoping Workshop can't represent, are
+// skipped and reported in a rulehelpers.RecordErrors error alongside the
+// remaining rules.
+func ConvertToWorkshopRules(zenRules []Rule, tagNames map[int]string) ([]*apipb.Rule, error) {
+	rules, _, err := convertRules(zenRules, tagNames)
+	return rules, err
+}
+
+func convertRules(zenRules []Rule, tagNames map[int]string) ([]*apipb.Rule, rulehelpers.Locations, error) {
+	rules := make([]*apipb.Rule, 0, len(zenRules))
+	locs := rulehelpers.Locations{}
+	var recordErrs rulehelpers.RecordErrors
+
+	for _, zenRule := range zenRules {
+		location := fmt.Sprintf("zentral rule %d", zenRule.ID)
+		converted, err := zenRule.ToWorkshopRules(tagNames)
+		if err != nil {
+			recordErrs = append(recordErrs, &rulehelpers.RecordError{
+				Location:   location,
+				Identifier: zenRule.TargetIdentifier,
+				Err:        err,
+			})
+			continue
+		}
+		for _, r := range converted {
+			locs[r] = location
+		}
+		rules = append(rules, converted...)
+	}
+
+	return rules, locs, recordErrs.Err()
+}
+
+// GetRulesFromZentral is a convenience function that fetches and converts
+// rules. Like ConvertToWorkshopRules, it may return a rulehelpers.RecordErrors
+// error alongside the rules that were converted.
+func GetRulesFromZentral(ctx context.Context, baseURL, token, targetType, targetIdentifier string, configurationID int) ([]*apipb.Rule, error) {
+	client := NewClient(baseURL, token)
+	return client.FetchRules(ctx, RuleFilter{
+		TargetType:       targetType,
+		TargetIdentifier: targetIdentifier,
+		ConfigurationID:  configurationID,
+	})
+}
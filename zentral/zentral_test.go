@@ -0,0 +1,951 @@
+package zentral_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shoenig/test"
+	"github.com/shoenig/test/must"
+
+	"github.com/northpolesec/santa-rule-importer/rulehelpers"
+	"github.com/northpolesec/santa-rule-importer/source"
+	"github.com/northpolesec/santa-rule-importer/zentral"
+
+	apipb "buf.build/gen/go/northpolesec/workshop-api/protocolbuffers/go/workshop/v1"
+)
+
+func TestNewClient(t *testing.T) {
+	baseURL := "https://zentral.example.com"
+	token := "test-token"
+
+	client := zentral.NewClient(baseURL, token)
+
+	test.Eq(t, baseURL, client.BaseURL)
+	test.Eq(t, token, client.Token)
+	must.NotNil(t, client.HTTPClient)
+	test.Eq(t, zentral.DefaultTimeout, client.HTTPClient.Timeout)
+}
+
+func TestClientGetRules(t *testing.T) {
+	// Load test data
+	testData, err := os.ReadFile("testdata/zentral_rules.json")
+	must.NoError(t, err)
+
+	// Create test server
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Verify the request
+		test.Eq(t, "/api/santa/rules/", r.URL.Path)
+		test.Eq(t, "Token test-token", r.Header.Get("Authorization"))
+		test.Eq(t, "application/json", r.Header.Get("Content-Type"))
+
+		// Return test data
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(testData)
+	}))
+	defer server.Close()
+
+	client := zentral.NewClient(server.URL, "test-token")
+	rules, err := client.GetRules(context.Background(), "", "", 0)
+
+	must.NoError(t, err)
+	test.Eq(t, 3, len(rules))
+
+	// Check first rule
+	test.Eq(t, 1, rules[0].ID)
+	test.Eq(t, "BINARY", rules[0].TargetType)
+	test.Eq(t, "a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3", rules[0].TargetIdentifier)
+	test.Eq(t, "BLOCKLIST", rules[0].Policy)
+	test.Eq(t, "Malicious binary detected", rules[0].CustomMsg)
+	test.Eq(t, "Known malware hash from threat intel", rules[0].Description)
+	test.Eq(t, 123, rules[0].ConfigurationID)
+
+	// Check second rule
+	test.Eq(t, 2, rules[1].ID)
+	test.Eq(t, "CERTIFICATE", rules[1].TargetType)
+	test.Eq(t, "59FB936AC5B6FED8A00F7F8CFBCD5A5A6F4F7D9A5D2E8A8A8A8A8A8A8A8A8A8A", rules[1].TargetIdentifier)
+	test.Eq(t, "ALLOWLIST", rules[1].Policy)
+	test.Eq(t, "Trusted certificate", rules[1].CustomMsg)
+	test.Eq(t, "Certificate for approved application", rules[1].Description)
+	test.Eq(t, 123, rules[1].ConfigurationID)
+
+	// Check third rule
+	test.Eq(t, 3, rules[2].ID)
+	test.Eq(t, "TEAMID", rules[2].TargetType)
+	test.Eq(t, "ABCDEF1234", rules[2].TargetIdentifier)
+	test.Eq(t, "ALLOWLIST", rules[2].Policy)
+	test.Eq(t, "", rules[2].CustomMsg)
+	test.Eq(t, "Apple Developer Team ID", rules[2].Description)
+	test.Eq(t, 456, rules[2].ConfigurationID)
+}
+
+func TestClientGetRulesWithFilters(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Verify path and query parameters
+		test.Eq(t, "/api/santa/rules/", r.URL.Path)
+		query := r.URL.Query()
+		test.Eq(t, "BINARY", query.Get("target_type"))
+		test.Eq(t, "somehash", query.Get("target_identifier"))
+		test.Eq(t, "123", query.Get("configuration_id"))
+
+		// Return empty result
+		response := zentral.APIResponse{
+			Count:    0,
+			Next:     nil,
+			Previous: nil,
+			Results:  []zentral.Rule{},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	client := zentral.NewClient(server.URL, "test-token")
+	rules, err := client.GetRules(context.Background(), "BINARY", "somehash", 123)
+
+	must.NoError(t, err)
+	test.Eq(t, 0, len(rules))
+}
+
+func TestClientGetRulesPaginated(t *testing.T) {
+	page1Data, err := os.ReadFile("testdata/zentral_paginated_page1.json")
+	must.NoError(t, err)
+
+	page2Data, err := os.ReadFile("testdata/zentral_paginated_page2.json")
+	must.NoError(t, err)
+
+	requestCount := 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		// Fix the pagination by updating the next URL to point to our test server
+		if r.URL.Query().Get("page") == "2" {
+			w.Write(page2Data)
+		} else {
+			// Replace the next URL with our test server URL
+			var response zentral.APIResponse
+			json.Unmarshal(page1Data, &response)
+			nextURL := server.URL + "/api/santa/rules/?page=2"
+			response.Next = &nextURL
+			json.NewEncoder(w).Encode(response)
+		}
+	}))
+	defer server.Close()
+
+	client := zentral.NewClient(server.URL, "test-token")
+	rules, err := client.GetRules(context.Background(), "", "", 0)
+
+	must.NoError(t, err)
+	test.Eq(t, 3, len(rules))   // Total rules across both pages
+	test.Eq(t, 2, requestCount) // Should have made 2 requests
+
+	// Check that we got rules from both pages
+	test.Eq(t, "hash1", rules[0].TargetIdentifier)
+	test.Eq(t, "cert1", rules[1].TargetIdentifier)
+	test.Eq(t, "team1", rules[2].TargetIdentifier)
+}
+
+func TestClientGetRulesAPIError(t *testing.T) {
+	requestCount := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Unauthorized"))
+	}))
+	defer server.Close()
+
+	client := zentral.NewClient(server.URL, "invalid-token")
+	_, err := client.GetRules(context.Background(), "", "", 0)
+
+	must.Error(t, err)
+	must.StrContains(t, err.Error(), "API request failed with status 401")
+	test.Eq(t, 1, requestCount)
+}
+
+func TestClientGetRulesRetries(t *testing.T) {
+	page1Data, err := os.ReadFile("testdata/zentral_paginated_page1.json")
+	must.NoError(t, err)
+
+	page2Data, err := os.ReadFile("testdata/zentral_paginated_page2.json")
+	must.NoError(t, err)
+
+	page1Requests, page2Requests := 0, 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "2" {
+			page1Requests++
+			var response zentral.APIResponse
+			json.Unmarshal(page1Data, &response)
+			nextURL := server.URL + "/api/santa/rules/?page=2"
+			response.Next = &nextURL
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+
+		// A proxy error, then throttling, then the page.
+		page2Requests++
+		switch page2Requests {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write(page2Data)
+		}
+	}))
+	defer server.Close()
+
+	client := zentral.NewClient(server.URL, "test-token")
+	client.InitialBackoff = time.Millisecond
+
+	start := time.Now()
+	rules, err := client.GetRules(context.Background(), "", "", 0)
+	must.NoError(t, err)
+	test.Eq(t, 3, len(rules))
+
+	// Only the failing page is requested again.
+	test.Eq(t, 1, page1Requests)
+	test.Eq(t, 3, page2Requests)
+	test.GreaterEq(t, time.Second, time.Since(start))
+}
+
+func TestClientGetRulesGivesUp(t *testing.T) {
+	requestCount := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := zentral.NewClient(server.URL, "test-token")
+	client.MaxAttempts = 3
+	client.InitialBackoff = time.Millisecond
+	_, err := client.GetRules(context.Background(), "", "", 0)
+
+	var apiErr *zentral.APIError
+	must.ErrorAs(t, err, &apiErr)
+	test.Eq(t, http.StatusServiceUnavailable, apiErr.StatusCode)
+	test.Eq(t, 3, requestCount)
+}
+
+func TestClientGetRulesCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	client := zentral.NewClient(server.URL, "test-token")
+	start := time.Now()
+	_, err := client.GetRules(ctx, "", "", 0)
+
+	must.Error(t, err)
+	test.Less(t, 10*time.Second, time.Since(start))
+}
+
+func TestClientGetRulesRetryAfterCapped(t *testing.T) {
+	requestCount := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCount++
+		if requestCount == 1 {
+			w.Header().Set("Retry-After", "86400")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+
+	client := zentral.NewClient(server.URL, "test-token")
+	client.MaxBackoff = 10 * time.Millisecond
+	start := time.Now()
+	_, err := client.GetRules(context.Background(), "", "", 0)
+
+	must.NoError(t, err)
+	test.Eq(t, 2, requestCount)
+	test.Less(t, 10*time.Second, time.Since(start))
+}
+
+func TestClientGetRulesInvalidJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("invalid json"))
+	}))
+	defer server.Close()
+
+	client := zentral.NewClient(server.URL, "test-token")
+	_, err := client.GetRules(context.Background(), "", "", 0)
+
+	must.Error(t, err)
+	must.StrContains(t, err.Error(), "failed to parse API response")
+}
+
+func TestConvertToWorkshopRules(t *testing.T) {
+	zenRules := []zentral.Rule{
+		{
+			ID:               1,
+			TargetType:       "BINARY",
+			TargetIdentifier: "hash123",
+			Policy:           "BLOCKLIST",
+			CustomMsg:        "Blocked binary",
+			Description:      "Malicious file",
+			ConfigurationID:  123,
+		},
+		{
+			ID:               2,
+			TargetType:       "CERTIFICATE",
+			TargetIdentifier: "cert456",
+			Policy:           "ALLOWLIST",
+			CustomMsg:        "Trusted cert",
+			Description:      "Known good certificate",
+			ConfigurationID:  456,
+		},
+	}
+
+	workshopRules, err := zentral.ConvertToWorkshopRules(zenRules, nil)
+	must.NoError(t, err)
+
+	test.Eq(t, 2, len(workshopRules))
+
+	// Check first rule conversion
+	test.Eq(t, "BINARY", workshopRules[0].RuleType.String())
+	test.Eq(t, "BLOCKLIST", workshopRules[0].Policy.String())
+	test.Eq(t, "hash123", workshopRules[0].Identifier)
+	test.Eq(t, "Blocked binary", workshopRules[0].CustomMsg)
+	test.Eq(t, "Malicious file", workshopRules[0].Comment)
+
+	// Check second rule conversion
+	test.Eq(t, "CERTIFICATE", workshopRules[1].RuleType.String())
+	test.Eq(t, "ALLOWLIST", workshopRules[1].Policy.String())
+	test.Eq(t, "cert456", workshopRules[1].Identifier)
+	test.Eq(t, "Trusted cert", workshopRules[1].CustomMsg)
+	test.Eq(t, "Known good certificate", workshopRules[1].Comment)
+}
+
+func TestConvertToWorkshopRulesInvalid(t *testing.T) {
+	zenRules := []zentral.Rule{
+		{ID: 1, TargetType: "BINARY", TargetIdentifier: "hash123", Policy: "BLOCKLIST"},
+		{ID: 2, TargetType: "BUNDLE", TargetIdentifier: "bundle456", Policy: "ALLOWLIST"},
+		{ID: 3, TargetType: "TEAMID", TargetIdentifier: "team789", Policy: "DENY"},
+	}
+
+	workshopRules, err := zentral.ConvertToWorkshopRules(zenRules, nil)
+
+	var recordErrs rulehelpers.RecordErrors
+	must.ErrorAs(t, err, &recordErrs)
+	must.Eq(t, 2, len(recordErrs))
+	test.Eq(t, "zentral rule 2", recordErrs[0].Location)
+	test.Eq(t, "bundle456", recordErrs[0].Identifier)
+	test.Eq(t, "zentral rule 3", recordErrs[1].Location)
+
+	must.Eq(t, 1, len(workshopRules))
+	test.Eq(t, "hash123", workshopRules[0].Identifier)
+}
+
+func TestGetRulesFromZentral(t *testing.T) {
+	testData, err := os.ReadFile("testdata/zentral_rules.json")
+	must.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(testData)
+	}))
+	defer server.Close()
+
+	rules, err := zentral.GetRulesFromZentral(context.Background(), server.URL, "test-token", "", "", 0)
+
+	must.NoError(t, err)
+	test.Eq(t, 3, len(rules))
+
+	// Check that rules are properly converted
+	test.Eq(t, "BINARY", rules[0].RuleType.String())
+	test.Eq(t, "BLOCKLIST", rules[0].Policy.String())
+	test.Eq(t, "a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3", rules[0].Identifier)
+	test.Eq(t, "Malicious binary detected", rules[0].CustomMsg)
+	test.Eq(t, "Known malware hash from threat intel", rules[0].Comment)
+}
+
+// newScopedServer serves the scoped rules of configuration 7, named
+// "Kiosks", and the tags they refer to.
+func newScopedServer(t *testing.T) *httptest.Server {
+	rulesData, err := os.ReadFile("testdata/zentral_scoped_rules.json")
+	must.NoError(t, err)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/santa/rules/":
+			test.Eq(t, "7", r.URL.Query().Get("configuration_id"))
+			w.Write(rulesData)
+		case "/api/santa/configurations/":
+			test.Eq(t, "Kiosks", r.URL.Query().Get("name"))
+			json.NewEncoder(w).Encode([]zentral.Configuration{{ID: 7, Name: "Kiosks"}})
+		case "/api/inventory/tags/":
+			json.NewEncoder(w).Encode(zentral.Page[zentral.Tag]{
+				Count:   3,
+				Results: []zentral.Tag{{ID: 1, Name: "kiosk"}, {ID: 2, Name: "lab"}, {ID: 3, Name: "staff"}},
+			})
+		default:
+			t.Errorf("unexpected request for %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestClientFetchRulesScoped(t *testing.T) {
+	server := newScopedServer(t)
+	defer server.Close()
+
+	client := zentral.NewClient(server.URL, "test-token")
+	rules, err := client.FetchRules(context.Background(), zentral.RuleFilter{ConfigurationName: "Kiosks"})
+
+	// Rules scoped by serial number or excluded tags are reported, not
+	// imported as global rules.
+	var recordErrs rulehelpers.RecordErrors
+	must.ErrorAs(t, err, &recordErrs)
+	must.Eq(t, 2, len(recordErrs))
+	test.Eq(t, "zentral rule 12", recordErrs[0].Location)
+	test.ErrorIs(t, recordErrs[0], zentral.ErrScoped)
+	test.ErrorContains(t, recordErrs[0], "scoped by serial_numbers")
+	test.Eq(t, "zentral rule 13", recordErrs[1].Location)
+	test.ErrorContains(t, recordErrs[1], "scoped by excluded_tags")
+
+	must.Eq(t, 3, len(rules))
+	test.Eq(t, "EQHXZ8M8AV", rules[0].GetIdentifier())
+	test.Eq(t, "", rules[0].GetTag())
+	test.Eq(t, "platform:com.apple.Terminal", rules[1].GetIdentifier())
+	test.Eq(t, "kiosk", rules[1].GetTag())
+	test.Eq(t, "platform:com.apple.Terminal", rules[2].GetIdentifier())
+	test.Eq(t, "lab", rules[2].GetTag())
+	test.Eq(t, "Terminal is not allowed on kiosks", rules[2].GetCustomMsg())
+}
+
+func TestClientFetchRulesByTag(t *testing.T) {
+	server := newScopedServer(t)
+	defer server.Close()
+
+	client := zentral.NewClient(server.URL, "test-token")
+	rules, err := client.FetchRules(context.Background(), zentral.RuleFilter{
+		ConfigurationID: 7,
+		Tags:            []string{"kiosk"},
+	})
+	must.NoError(t, err)
+
+	// The Terminal rule is also scoped to the lab tag, which isn't selected.
+	must.Eq(t, 1, len(rules))
+	test.Eq(t, "platform:com.apple.Terminal", rules[0].GetIdentifier())
+	test.Eq(t, "kiosk", rules[0].GetTag())
+
+	_, err = client.FetchRules(context.Background(), zentral.RuleFilter{
+		ConfigurationID: 7,
+		Tags:            []string{"servers"},
+	})
+	test.ErrorContains(t, err, `no Zentral tag named "servers"`)
+}
+
+func TestClientFetchRulesUnknownConfiguration(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+
+	client := zentral.NewClient(server.URL, "test-token")
+	_, err := client.FetchRules(context.Background(), zentral.RuleFilter{ConfigurationName: "Kiosks"})
+	test.ErrorContains(t, err, `no Zentral configuration named "Kiosks"`)
+}
+
+func TestRuleToWorkshopRuleScoped(t *testing.T) {
+	zenRule := zentral.Rule{
+		ID:               1,
+		TargetType:       "TEAMID",
+		TargetIdentifier: "EQHXZ8M8AV",
+		Policy:           "BLOCKLIST",
+		Tags:             []int{1},
+	}
+
+	_, err := zenRule.ToWorkshopRule()
+	test.ErrorIs(t, err, zentral.ErrScoped)
+
+	_, err = zenRule.ToWorkshopRules(map[int]string{1: "host:C0FFEE00-1234-5678-9ABC-DEF012345678"})
+	test.ErrorContains(t, err, "not a valid Workshop tag")
+}
+
+// newReportServer serves the scoped rules of configuration 7 with the
+// configurations, tags, targets and ballots of a migration report.
+func newReportServer(t *testing.T) *httptest.Server {
+	files := map[string]string{
+		"/api/santa/rules/":          "testdata/zentral_scoped_rules.json",
+		"/api/santa/configurations/": "testdata/zentral_configurations.json",
+		"/api/santa/targets/":        "testdata/zentral_targets.json",
+		"/api/santa/ballots/":        "testdata/zentral_ballots.json",
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/inventory/tags/" {
+			json.NewEncoder(w).Encode([]zentral.Tag{{ID: 1, Name: "kiosk"}, {ID: 2, Name: "lab"}, {ID: 3, Name: "staff"}})
+			return
+		}
+		data, err := os.ReadFile(files[r.URL.Path])
+		if err != nil {
+			t.Errorf("unexpected request for %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	}))
+}
+
+func TestClientReport(t *testing.T) {
+	server := newReportServer(t)
+	defer server.Close()
+
+	client := zentral.NewClient(server.URL, "test-token")
+	report, err := client.Report(context.Background(), zentral.RuleFilter{ConfigurationName: "Kiosks"})
+	must.NoError(t, err)
+	must.Eq(t, 1, len(report.Configurations))
+
+	kiosks := report.Configurations[0]
+	test.Eq(t, "Kiosks", kiosks.Configuration.Name)
+	test.Eq(t, 2.0, kiosks.Configuration.Settings["client_mode"])
+	test.MapNotContainsKey(t, kiosks.Configuration.Settings, "created_at")
+
+	settings := map[string]zentral.SettingReport{}
+	for _, s := range kiosks.Settings {
+		settings[s.Key] = s
+	}
+	test.Eq(t, "blocked_path_regex", settings["blocked_path_regex"].Workshop)
+	test.Eq(t, "enable_all_event_upload", settings["enable_all_event_upload_shard"].Workshop)
+	test.Eq(t, "", settings["banned_threshold"].Workshop)
+	test.Eq(t, "Workshop has no ballot voting", settings["banned_threshold"].Note)
+
+	must.Eq(t, 4, len(kiosks.Rules))
+	google := kiosks.Rules[0]
+	test.Eq(t, 10, google.Rule.ID)
+	must.NotNil(t, google.Target)
+	test.Eq(t, "Google LLC", google.Target.Name)
+	test.Eq(t, 1, google.YesVotes)
+	test.Eq(t, 1, google.NoVotes)
+	test.NoError(t, google.Err)
+
+	terminal := kiosks.Rules[1]
+	test.Eq(t, []string{"kiosk", "lab"}, terminal.Tags)
+	test.Eq(t, 0, terminal.YesVotes+terminal.NoVotes)
+
+	test.ErrorIs(t, kiosks.Rules[2].Err, zentral.ErrScoped)
+
+	// Slack was voted on in the configuration but has no rule.
+	must.Eq(t, 1, len(kiosks.VotedTargets))
+	test.Eq(t, "Slack", kiosks.VotedTargets[0].Name)
+
+	// Without a configuration filter, configurations without rules are
+	// reported too.
+	report, err = client.Report(context.Background(), zentral.RuleFilter{})
+	must.NoError(t, err)
+	must.Eq(t, 2, len(report.Configurations))
+	engineering := report.Configurations[1]
+	test.Eq(t, "Engineering", engineering.Configuration.Name)
+	test.Eq(t, 0, len(engineering.Rules))
+	must.Eq(t, 1, len(engineering.VotedTargets))
+	test.Eq(t, "EQHXZ8M8AV", engineering.VotedTargets[0].Identifier)
+	for _, s := range engineering.Settings {
+		if s.Key == "enable_all_event_upload_shard" {
+			test.Eq(t, "", s.Workshop)
+		}
+	}
+
+	var b strings.Builder
+	must.NoError(t, report.WriteText(&b))
+	text := b.String()
+	test.StrContains(t, text, `Configuration 7 "Kiosks": 4 rules, 2 can't be imported, 1 targets voted on without a rule`)
+	test.StrContains(t, text, "  + 10 TEAMID ALLOWLIST EQHXZ8M8AV (Google LLC), 1 yes and 1 no votes\n")
+	test.StrContains(t, text, "  + 11 SIGNINGID BLOCKLIST platform:com.apple.Terminal (Terminal), tags kiosk, lab\n")
+	test.StrContains(t, text, "  - banned_threshold = -26 (Workshop has no ballot voting)\n")
+	test.StrContains(t, text, "  + client_mode = 2 -> client_mode\n")
+	test.StrContains(t, text, `Configuration 8 "Engineering": 0 rules`)
+
+	// Targets and ballots are filtered like the rules.
+	report, err = client.Report(context.Background(), zentral.RuleFilter{ConfigurationID: 7, TargetType: "TEAMID"})
+	must.NoError(t, err)
+	must.Eq(t, 1, len(report.Configurations))
+	test.Eq(t, 0, len(report.Configurations[0].VotedTargets))
+}
+
+func TestReportSource(t *testing.T) {
+	reportServer := newReportServer(t)
+	defer reportServer.Close()
+
+	// The report is built from the rules fetched for the import.
+	rulesRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/santa/rules/" {
+			rulesRequests++
+		}
+		reportServer.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	t.Setenv("ZENTRAL_API_KEY", "test-token")
+	reg, ok := source.Lookup("zentral")
+	must.True(t, ok)
+
+	reportPath := filepath.Join(t.TempDir(), "report.txt")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	reg.Driver.SetFlags(fs)
+	must.NoError(t, fs.Parse([]string{"--zentral-config-id", "7", "--zentral-report", reportPath}))
+
+	src, err := reg.Driver.Open(server.URL)
+	must.NoError(t, err)
+	rules, err := src.Rules(context.Background())
+	var recordErrs rulehelpers.RecordErrors
+	must.ErrorAs(t, err, &recordErrs)
+	test.Eq(t, 3, len(rules))
+	test.Eq(t, 1, rulesRequests)
+
+	data, err := os.ReadFile(reportPath)
+	must.NoError(t, err)
+	test.StrContains(t, string(data), `Configuration 7 "Kiosks": 4 rules, 2 can't be imported`)
+}
+
+func TestState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zentral.state")
+
+	state, err := zentral.LoadState(path)
+	must.NoError(t, err)
+
+	zenRules := []zentral.Rule{
+		{ID: 1, TargetType: "BINARY", TargetIdentifier: "hash1", Policy: "BLOCKLIST", UpdatedAt: "2025-01-01T10:00:00Z"},
+		{ID: 2, TargetType: "TEAMID", TargetIdentifier: "team1", Policy: "ALLOWLIST", UpdatedAt: "2025-01-02T10:00:00.123456+00:00"},
+		{ID: 3, TargetType: "TEAMID", TargetIdentifier: "team2", Policy: "ALLOWLIST", UpdatedAt: "2025-01-02T09:00:00Z"},
+	}
+
+	changed, deleted := state.Changes(zenRules)
+	test.Eq(t, 3, len(changed))
+	test.Eq(t, 0, len(deleted))
+
+	must.NoError(t, state.Next(zenRules).Save(path))
+	state, err = zentral.LoadState(path)
+	must.NoError(t, err)
+	test.Eq(t, time.Date(2025, 1, 2, 10, 0, 0, 123456000, time.UTC), state.UpdatedAt.UTC())
+
+	// Rule 1 is updated, rule 3 deleted and rule 4 created with a timestamp
+	// older than the high-water mark.
+	zenRules[0].Policy = "ALLOWLIST"
+	zenRules[0].UpdatedAt = "2025-01-03T10:00:00Z"
+	zenRules[2] = zentral.Rule{ID: 4, TargetType: "TEAMID", TargetIdentifier: "team3", Policy: "ALLOWLIST", UpdatedAt: "2025-01-02T08:00:00Z"}
+
+	changed, deleted = state.Changes(zenRules)
+	must.Eq(t, 2, len(changed))
+	test.Eq(t, 1, changed[0].ID)
+	test.Eq(t, 4, changed[1].ID)
+	must.Eq(t, 1, len(deleted))
+	test.Eq(t, 3, deleted[0].ID)
+	test.Eq(t, "team2", deleted[0].TargetIdentifier)
+
+	// Timestamps without a time zone are UTC and still advance the mark.
+	zenRules[1].UpdatedAt = "2025-01-04T10:00:00.5"
+	next := state.Next(zenRules)
+	test.Eq(t, time.Date(2025, 1, 4, 10, 0, 0, 500000000, time.UTC), next.UpdatedAt)
+	changed, _ = next.Changes(zenRules)
+	test.Eq(t, 0, len(changed))
+}
+
+func TestRuleFilterString(t *testing.T) {
+	a := zentral.RuleFilter{ConfigurationID: 3, Tags: []string{"b", "a"}}
+	b := zentral.RuleFilter{ConfigurationID: 3, Tags: []string{"a", "b"}}
+	test.Eq(t, a.String(), b.String())
+	test.NotEq(t, a.String(), zentral.RuleFilter{ConfigurationID: 3}.String())
+	test.NotEq(t, a.String(), zentral.RuleFilter{ConfigurationID: 4, Tags: []string{"a", "b"}}.String())
+}
+
+func TestIncrementalSource(t *testing.T) {
+	testData, err := os.ReadFile("testdata/zentral_rules.json")
+	must.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testData)
+	}))
+	defer server.Close()
+
+	t.Setenv("ZENTRAL_API_KEY", "test-token")
+	reg, ok := source.Lookup("zentral")
+	must.True(t, ok)
+
+	statePath := filepath.Join(t.TempDir(), "zentral.state")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	reg.Driver.SetFlags(fs)
+	must.NoError(t, fs.Parse([]string{"--zentral-state", statePath}))
+
+	open := func() source.Source {
+		src, err := reg.Driver.Open(server.URL)
+		must.NoError(t, err)
+		return src
+	}
+
+	// Nothing is recorded until the source is committed.
+	for range 2 {
+		rules, err := open().Rules(context.Background())
+		must.NoError(t, err)
+		test.Eq(t, 3, len(rules))
+	}
+
+	src := open()
+	rules, err := src.Rules(context.Background())
+	must.NoError(t, err)
+	committer, ok := src.(source.Committer)
+	must.True(t, ok)
+	must.NoError(t, committer.Commit())
+
+	// Rules are located by their Zentral rule ID.
+	locator, ok := src.(source.Locator)
+	must.True(t, ok)
+	test.Eq(t, "zentral rule 1", locator.Locations()[rules[0]])
+
+	rules, err = open().Rules(context.Background())
+	must.NoError(t, err)
+	test.Eq(t, 0, len(rules))
+}
+
+func TestFromWorkshopRules(t *testing.T) {
+	newRule := func(ruleType, policy, identifier, tag string) *apipb.Rule {
+		r, err := rulehelpers.NewRule(ruleType, policy, identifier)
+		must.NoError(t, err)
+		r.Tag = tag
+		return r
+	}
+
+	zenRules, err := zentral.FromWorkshopRules([]*apipb.Rule{
+		newRule("TEAMID", "ALLOWLIST", "EQHXZ8M8AV", ""),
+		newRule("TEAMID", "ALLOWLIST", "EQHXZ8M8AV", "kiosk"),
+		newRule("SIGNINGID", "BLOCKLIST", "platform:com.apple.Terminal", "lab"),
+		newRule("SIGNINGID", "BLOCKLIST", "platform:com.apple.Terminal", "kiosk"),
+		newRule("BINARY", "BLOCKLIST", "a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3", ""),
+		newRule("BINARY", "ALLOWLIST", "a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3", "lab"),
+		newRule("TEAMID", "BLOCKLIST", "UBF8T346G9", "host:C0FFEE00-1234-5678-9ABC-DEF012345678"),
+	}, 7, map[string]int{"kiosk": 1, "lab": 2})
+
+	// The global rule covers the kiosk rule, the tagged rules are merged and
+	// the rest are reported.
+	must.Eq(t, 2, len(zenRules))
+	test.Eq(t, "EQHXZ8M8AV", zenRules[0].TargetIdentifier)
+	test.Eq(t, 7, zenRules[0].ConfigurationID)
+	test.Eq(t, 0, len(zenRules[0].Tags))
+	test.Eq(t, "platform:com.apple.Terminal", zenRules[1].TargetIdentifier)
+	test.Eq(t, []int{1, 2}, zenRules[1].Tags)
+
+	var recordErrs rulehelpers.RecordErrors
+	must.ErrorAs(t, err, &recordErrs)
+	must.Eq(t, 2, len(recordErrs))
+	test.ErrorIs(t, recordErrs[0], zentral.ErrConflict)
+	test.ErrorContains(t, recordErrs[1], `no Zentral tag named "host:C0FFEE00-1234-5678-9ABC-DEF012345678"`)
+}
+
+func TestClientPush(t *testing.T) {
+	existing := []zentral.Rule{
+		{ID: 10, TargetType: "TEAMID", TargetIdentifier: "EQHXZ8M8AV", Policy: "ALLOWLIST", Description: "Google", ConfigurationID: 7},
+		{ID: 12, TargetType: "BINARY", TargetIdentifier: "a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3", Policy: "BLOCKLIST", ConfigurationID: 7, SerialNumbers: []string{"C02XK1ZZJGH5"}},
+		{ID: 13, TargetType: "TEAMID", TargetIdentifier: "UBF8T346G9", Policy: "ALLOWLIST", ConfigurationID: 7},
+	}
+
+	type request struct {
+		method, path string
+		body         map[string]any
+	}
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		test.Eq(t, "Token test-token", r.Header.Get("Authorization"))
+		if r.Method == http.MethodGet {
+			test.Eq(t, "7", r.URL.Query().Get("configuration_id"))
+			json.NewEncoder(w).Encode(existing)
+			return
+		}
+
+		req := request{method: r.Method, path: r.URL.Path}
+		if r.Method != http.MethodDelete {
+			must.NoError(t, json.NewDecoder(r.Body).Decode(&req.body))
+		}
+		requests = append(requests, req)
+
+		switch r.Method {
+		case http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+			req.body["id"] = 14
+			json.NewEncoder(w).Encode(req.body)
+		case http.MethodPut:
+			json.NewEncoder(w).Encode(req.body)
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	newRule := func(ruleType, policy, identifier, comment string) *apipb.Rule {
+		r, err := rulehelpers.NewRule(ruleType, policy, identifier)
+		must.NoError(t, err)
+		r.Comment = comment
+		return r
+	}
+	rules := []*apipb.Rule{
+		newRule("TEAMID", "ALLOWLIST", "EQHXZ8M8AV", "Google"),
+		newRule("BINARY", "BLOCKLIST", "a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3", "Blocked"),
+		newRule("SIGNINGID", "BLOCKLIST", "platform:com.apple.Terminal", ""),
+	}
+
+	client := zentral.NewClient(server.URL, "test-token")
+	plan, err := client.PlanPush(context.Background(), 7, rules, nil, rulehelpers.Filter{}, true)
+	must.NoError(t, err)
+	test.Eq(t, 1, len(plan.Unchanged))
+	must.Eq(t, 1, len(plan.Create))
+	test.Eq(t, "platform:com.apple.Terminal", plan.Create[0].TargetIdentifier)
+	must.Eq(t, 1, len(plan.Update))
+	test.Eq(t, 12, plan.Update[0].Rule.ID)
+	test.Eq(t, []string{"description"}, plan.Update[0].Changes())
+	must.Eq(t, 1, len(plan.Delete))
+	test.Eq(t, 13, plan.Delete[0].ID)
+
+	var b strings.Builder
+	must.NoError(t, plan.WriteText(&b))
+	test.StrContains(t, b.String(), "Zentral configuration 7: 1 to create, 1 to update, 1 to delete, 1 unchanged, 0 invalid\n")
+	test.StrContains(t, b.String(), "  ~ 12 BINARY BLOCKLIST a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3 (description)\n")
+	test.StrContains(t, b.String(), "  - 13 TEAMID ALLOWLIST UBF8T346G9\n")
+
+	// Planning doesn't change anything.
+	test.Eq(t, 0, len(requests))
+
+	applied, err := client.Push(context.Background(), plan)
+	must.NoError(t, err)
+	test.Eq(t, 3, applied)
+	must.Eq(t, 3, len(requests))
+
+	test.Eq(t, http.MethodPost, requests[0].method)
+	test.Eq(t, "/api/santa/rules/", requests[0].path)
+	test.Eq(t, "SIGNINGID", requests[0].body["target_type"])
+	test.Eq(t, 7.0, requests[0].body["configuration"])
+
+	// Updates keep the scoping of the existing rule.
+	test.Eq(t, http.MethodPut, requests[1].method)
+	test.Eq(t, "/api/santa/rules/12/", requests[1].path)
+	test.Eq[any](t, []any{"C02XK1ZZJGH5"}, requests[1].body["serial_numbers"])
+	test.Eq[any](t, []any{}, requests[1].body["primary_users"])
+
+	test.Eq(t, http.MethodDelete, requests[2].method)
+	test.Eq(t, "/api/santa/rules/13/", requests[2].path)
+}
+
+func TestDiffRulesKeepsInvalid(t *testing.T) {
+	existing := []zentral.Rule{
+		{ID: 10, TargetType: "TEAMID", TargetIdentifier: "EQHXZ8M8AV", Policy: "ALLOWLIST"},
+		{ID: 12, TargetType: "BINARY", TargetIdentifier: "a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3", Policy: "BLOCKLIST"},
+		{ID: 13, TargetType: "TEAMID", TargetIdentifier: "UBF8T346G9", Policy: "ALLOWLIST"},
+	}
+	rules := []zentral.Rule{
+		{TargetType: "TEAMID", TargetIdentifier: "EQHXZ8M8AV", Policy: "ALLOWLIST"},
+	}
+
+	// The Zentral rule of a record that couldn't be read is kept.
+	invalid := rulehelpers.RecordErrors{{
+		Location:   "line 3",
+		Identifier: "A665A45920422F9D417E4867EFDC4FB8A04A1F3FFF1FA07E998E86F7F7A27AE3",
+		Err:        errors.New("unknown policy type"),
+	}}
+	plan := zentral.DiffRules(rules, existing, invalid, rulehelpers.Filter{}, true)
+	must.Eq(t, 1, len(plan.Delete))
+	test.Eq(t, 13, plan.Delete[0].ID)
+	test.Eq(t, 1, len(plan.Invalid))
+
+	// A record without an identifier could be any rule, so none is deleted.
+	invalid = append(invalid, &rulehelpers.RecordError{Location: "line 4", Err: errors.New("wrong number of fields")})
+	plan = zentral.DiffRules(rules, existing, invalid, rulehelpers.Filter{}, true)
+	test.Eq(t, 0, len(plan.Delete))
+}
+
+func TestDiffRulesFiltered(t *testing.T) {
+	existing := []zentral.Rule{
+		{ID: 10, TargetType: "BINARY", TargetIdentifier: "a", Policy: "ALLOWLIST"},
+		{ID: 11, TargetType: "BINARY", TargetIdentifier: "b", Policy: "BLOCKLIST"},
+		{ID: 12, TargetType: "BINARY", TargetIdentifier: "c", Policy: "BLOCKLIST"},
+	}
+	rules := []zentral.Rule{
+		{TargetType: "BINARY", TargetIdentifier: "b", Policy: "BLOCKLIST"},
+	}
+
+	// Only the rules the filter selects can be missing from the push.
+	filter, err := rulehelpers.ParseFilter("", "BLOCKLIST")
+	must.NoError(t, err)
+	plan := zentral.DiffRules(rules, existing, nil, filter, true)
+	must.Eq(t, 1, len(plan.Delete))
+	test.Eq(t, 12, plan.Delete[0].ID)
+
+	plan = zentral.DiffRules(rules, existing, nil, rulehelpers.Filter{}, true)
+	test.Eq(t, 2, len(plan.Delete))
+}
+
+func TestDiffRulesKeepsTagScoping(t *testing.T) {
+	existing := []zentral.Rule{
+		{ID: 10, TargetType: "BINARY", TargetIdentifier: "a", Policy: "BLOCKLIST", Tags: []int{1}},
+		{ID: 11, TargetType: "BINARY", TargetIdentifier: "b", Policy: "BLOCKLIST", Tags: []int{1}},
+	}
+	rules := []zentral.Rule{
+		{TargetType: "BINARY", TargetIdentifier: "a", Policy: "BLOCKLIST"},
+		{TargetType: "BINARY", TargetIdentifier: "b", Policy: "BLOCKLIST", Tags: []int{2}},
+	}
+
+	// A global rule doesn't widen a tagged Zentral rule to every machine,
+	// and isn't deleted either.
+	plan := zentral.DiffRules(rules, existing, nil, rulehelpers.Filter{}, true)
+	must.Eq(t, 1, len(plan.Invalid))
+	test.ErrorIs(t, plan.Invalid[0], zentral.ErrWidened)
+	test.Eq(t, "zentral rule 10", plan.Invalid[0].Location)
+	must.Eq(t, 1, len(plan.Update))
+	test.Eq(t, 11, plan.Update[0].Rule.ID)
+	test.Eq(t, 0, len(plan.Delete))
+}
+
+func TestClientCreateRuleRetries(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		switch attempts {
+		case 1:
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			t.Errorf("unexpected attempt %d", attempts)
+		}
+	}))
+	defer server.Close()
+
+	client := zentral.NewClient(server.URL, "test-token")
+	client.InitialBackoff = time.Millisecond
+
+	// Throttled creates are retried, but a create that may have been applied
+	// is not.
+	_, err := client.CreateRule(context.Background(), zentral.Rule{TargetType: "TEAMID", TargetIdentifier: "EQHXZ8M8AV", Policy: "ALLOWLIST"})
+	var apiErr *zentral.APIError
+	must.ErrorAs(t, err, &apiErr)
+	test.Eq(t, http.StatusBadGateway, apiErr.StatusCode)
+	test.Eq(t, 2, attempts)
+}